@@ -5,11 +5,125 @@
 package gocelery
 
 import (
+	"errors"
 	"log"
+	"time"
 
 	amqp "github.com/rabbitmq/amqp091-go"
 )
 
+// ErrAMQPDisconnected is returned while connection to AMQP server is down
+var ErrAMQPDisconnected = errors.New("amqp: disconnected from server")
+
+// ErrAMQPPublishNacked is returned when server did not take published message
+var ErrAMQPPublishNacked = errors.New("amqp: message was nacked by server")
+
+// ErrAMQPPublishReturned is returned when mandatory message could not be routed to any queue
+var ErrAMQPPublishReturned = errors.New("amqp: message was returned by server")
+
+// ErrAMQPConfirmTimeout is returned when server did not confirm message in time
+var ErrAMQPConfirmTimeout = errors.New("amqp: timed out waiting for publisher confirm")
+
+// errAMQPNoHost is returned when connection cannot be re-established without host
+var errAMQPNoHost = errors.New("amqp: no host to reconnect to")
+
+// errAMQPConnectionClosed is returned when channel cannot be reopened on closed connection
+var errAMQPConnectionClosed = errors.New("amqp: connection is closed")
+
+const (
+	amqpReconnectMinBackoff = 100 * time.Millisecond
+	amqpReconnectMaxBackoff = 30 * time.Second
+)
+
+// NewAMQPConnection creates new AMQP channel
+func NewAMQPConnection(host string) (*amqp.Connection, *amqp.Channel, error) {
+	connection, err := amqp.Dial(host)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	channel, err := connection.Channel()
+	if err != nil {
+		connection.Close()
+		return nil, nil, err
+	}
+	return connection, channel, nil
+}
+
+// superviseConnection waits for connection or channel to be lost. Lost connection
+// is redialed with backoff until reconnect succeeds, channel closed by exception
+// while connection is up is opened again by reopen. It returns when connection
+// or channel is closed on purpose.
+func superviseConnection(conn *amqp.Connection, channel *amqp.Channel,
+	reconnect func() (*amqp.Connection, *amqp.Channel, error), reopen func(*amqp.Connection) (*amqp.Channel, error)) {
+	for {
+		connClosed := conn.NotifyClose(make(chan *amqp.Error, 1))
+		channelClosed := channel.NotifyClose(make(chan *amqp.Error, 1))
+		select {
+		case closeErr, ok := <-connClosed:
+			if !ok || closeErr == nil {
+				return
+			}
+			log.Printf("amqp: connection lost: %v", closeErr)
+		case closeErr, ok := <-channelClosed:
+			if !conn.IsClosed() {
+				if !ok || closeErr == nil {
+					return
+				}
+				log.Printf("amqp: channel closed: %v", closeErr)
+				if newChannel, err := retryAMQP(func() (*amqp.Channel, error) {
+					if conn.IsClosed() {
+						return nil, errAMQPConnectionClosed
+					}
+					return reopen(conn)
+				}); err == nil {
+					channel = newChannel
+					log.Printf("amqp: channel reopened")
+					continue
+				}
+			}
+			// channel is closed with its connection
+			if closeErr, ok := <-connClosed; !ok || closeErr == nil {
+				return
+			}
+			log.Printf("amqp: connection lost: %v", closeErr)
+		}
+		newChannel, err := retryAMQP(func() (*amqp.Channel, error) {
+			newConn, newChannel, err := reconnect()
+			if err == nil {
+				conn = newConn
+			}
+			return newChannel, err
+		})
+		if err != nil {
+			log.Printf("amqp: giving up reconnection: %v", err)
+			return
+		}
+		channel = newChannel
+		log.Printf("amqp: reconnected")
+	}
+}
+
+// retryAMQP calls open with backoff until it succeeds. It gives up when
+// open fails with errAMQPNoHost or errAMQPConnectionClosed.
+func retryAMQP(open func() (*amqp.Channel, error)) (*amqp.Channel, error) {
+	backoff := amqpReconnectMinBackoff
+	for {
+		channel, err := open()
+		if err == nil {
+			return channel, nil
+		}
+		if errors.Is(err, errAMQPNoHost) || errors.Is(err, errAMQPConnectionClosed) {
+			return nil, err
+		}
+		log.Printf("amqp: failed to reconnect, retrying in %v: %v", backoff, err)
+		time.Sleep(backoff)
+		if backoff *= 2; backoff > amqpReconnectMaxBackoff {
+			backoff = amqpReconnectMaxBackoff
+		}
+	}
+}
+
 // deliveryAck acknowledges delivery message with retries on error
 func deliveryAck(delivery amqp.Delivery) {
 	var err error
@@ -6,7 +6,9 @@ package gocelery
 
 import (
 	"encoding/json"
+	"fmt"
 	"strings"
+	"sync"
 	"time"
 
 	amqp "github.com/rabbitmq/amqp091-go"
@@ -16,41 +18,106 @@ import (
 type AMQPCeleryBackend struct {
 	*amqp.Channel
 	Connection *amqp.Connection
-	Host       string
+	// Host is dialed again when connection to AMQP server is lost
+	Host        string
+	channelLock sync.RWMutex
 }
 
 // NewAMQPCeleryBackend creates new AMQPCeleryBackend
-func NewAMQPCeleryBackend(host string) *AMQPCeleryBackend {
-	backend := NewAMQPCeleryBackendByConnAndChannel(NewAMQPConnection(host))
-	backend.Host = host
-	return backend
+func NewAMQPCeleryBackend(host string) (*AMQPCeleryBackend, error) {
+	conn, channel, err := NewAMQPConnection(host)
+	if err != nil {
+		return nil, err
+	}
+	return newAMQPCeleryBackend(conn, channel, host), nil
 }
 
-// NewAMQPCeleryBackendByConnAndChannel creates new AMQPCeleryBackend by AMQP connection and channel
+// NewAMQPCeleryBackendByConnAndChannel creates new AMQPCeleryBackend by AMQP connection and channel.
+// Closed channel is opened again, but lost connection is not redialed without Host.
 func NewAMQPCeleryBackendByConnAndChannel(conn *amqp.Connection, channel *amqp.Channel) *AMQPCeleryBackend {
+	return newAMQPCeleryBackend(conn, channel, "")
+}
+
+// newAMQPCeleryBackend creates new AMQPCeleryBackend and supervises its connection
+func newAMQPCeleryBackend(conn *amqp.Connection, channel *amqp.Channel, host string) *AMQPCeleryBackend {
 	backend := &AMQPCeleryBackend{
 		Channel:    channel,
 		Connection: conn,
+		Host:       host,
 	}
+	go superviseConnection(conn, channel, backend.reconnect, backend.reopen)
 	return backend
 }
 
 // Reconnect reconnects to AMQP server
-func (b *AMQPCeleryBackend) Reconnect() {
-	b.Connection.Close()
-	conn, channel := NewAMQPConnection(b.Host)
-	b.Channel = channel
-	b.Connection = conn
+func (b *AMQPCeleryBackend) Reconnect() error {
+	b.channelLock.RLock()
+	conn := b.Connection
+	b.channelLock.RUnlock()
+	if conn != nil {
+		conn.Close()
+	}
+	conn, channel, err := b.reconnect()
+	if err != nil {
+		return err
+	}
+	go superviseConnection(conn, channel, b.reconnect, b.reopen)
+	return nil
+}
+
+// reconnect dials Host again and replaces connection and channel
+func (b *AMQPCeleryBackend) reconnect() (*amqp.Connection, *amqp.Channel, error) {
+	if b.Host == "" {
+		return nil, nil, errAMQPNoHost
+	}
+	conn, err := amqp.Dial(b.Host)
+	if err != nil {
+		return nil, nil, err
+	}
+	channel, err := b.reopen(conn)
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	return conn, channel, nil
+}
+
+// reopen opens new channel on conn and replaces connection and channel
+func (b *AMQPCeleryBackend) reopen(conn *amqp.Connection) (*amqp.Channel, error) {
+	channel, err := conn.Channel()
+	if err != nil {
+		return nil, err
+	}
+	b.channelLock.Lock()
+	b.Connection, b.Channel = conn, channel
+	b.channelLock.Unlock()
+	return channel, nil
+}
+
+// channel returns current AMQP channel or ErrAMQPDisconnected while it is down
+func (b *AMQPCeleryBackend) channel() (*amqp.Channel, error) {
+	b.channelLock.RLock()
+	channel := b.Channel
+	b.channelLock.RUnlock()
+	if channel == nil || channel.IsClosed() {
+		return nil, ErrAMQPDisconnected
+	}
+	return channel, nil
 }
 
 // GetResult retrieves result from AMQP queue
 func (b *AMQPCeleryBackend) GetResult(taskID string) (*ResultMessage, error) {
 
+	channel, err := b.channel()
+	if err != nil {
+		return nil, err
+	}
+
 	queueName := strings.Replace(taskID, "-", "", -1)
 
 	args := amqp.Table{"x-expires": int32(86400000)}
 
-	_, err := b.QueueDeclare(
+	_, err = channel.QueueDeclare(
 		queueName, // name
 		true,      // durable
 		true,      // autoDelete
@@ -62,7 +129,7 @@ func (b *AMQPCeleryBackend) GetResult(taskID string) (*ResultMessage, error) {
 		return nil, err
 	}
 
-	err = b.ExchangeDeclare(
+	err = channel.ExchangeDeclare(
 		"default",
 		"direct",
 		true,
@@ -75,16 +142,16 @@ func (b *AMQPCeleryBackend) GetResult(taskID string) (*ResultMessage, error) {
 		return nil, err
 	}
 
-	// open channel temporarily
-	channel, err := b.Consume(queueName, "", false, false, false, false, nil)
+	delivery, ok, err := channel.Get(queueName, false)
 	if err != nil {
 		return nil, err
 	}
+	if !ok {
+		return nil, fmt.Errorf("result not available")
+	}
+	deliveryAck(delivery)
 
 	var resultMessage ResultMessage
-
-	delivery := <-channel
-	deliveryAck(delivery)
 	if err := json.Unmarshal(delivery.Body, &resultMessage); err != nil {
 		return nil, err
 	}
@@ -102,8 +169,13 @@ func (b *AMQPCeleryBackend) SetResult(taskID string, result *ResultMessage) erro
 	// autodelete is automatically set to true by python
 	// (406) PRECONDITION_FAILED - inequivalent arg 'durable' for queue 'bc58c0d895c7421eb7cb2b9bbbd8b36f' in vhost '/': received 'true' but current is 'false'
 
+	channel, err := b.channel()
+	if err != nil {
+		return err
+	}
+
 	args := amqp.Table{"x-expires": int32(86400000)}
-	_, err := b.QueueDeclare(
+	_, err = channel.QueueDeclare(
 		queueName, // name
 		true,      // durable
 		true,      // autoDelete
@@ -115,7 +187,7 @@ func (b *AMQPCeleryBackend) SetResult(taskID string, result *ResultMessage) erro
 		return err
 	}
 
-	err = b.ExchangeDeclare(
+	err = channel.ExchangeDeclare(
 		"default",
 		"direct",
 		true,
@@ -139,7 +211,7 @@ func (b *AMQPCeleryBackend) SetResult(taskID string, result *ResultMessage) erro
 		ContentType:  "application/json",
 		Body:         resBytes,
 	}
-	return b.Publish(
+	return channel.Publish(
 		"",
 		queueName,
 		false,
@@ -5,10 +5,17 @@
 package gocelery
 
 import (
+	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/google/uuid"
 	amqp "github.com/rabbitmq/amqp091-go"
 )
 
@@ -18,6 +25,7 @@ type AMQPExchange struct {
 	Type       string
 	Durable    bool
 	AutoDelete bool
+	Args       amqp.Table
 }
 
 // NewAMQPExchange creates new AMQPExchange
@@ -30,11 +38,27 @@ func NewAMQPExchange(name string) *AMQPExchange {
 	}
 }
 
-// AMQPQueue stores AMQP Queue configuration
+// AMQPQueue stores AMQP Queue configuration.
+// Queue must be declared with the same settings everywhere,
+// otherwise server closes channel with PRECONDITION_FAILED.
 type AMQPQueue struct {
 	Name       string
 	Durable    bool
 	AutoDelete bool
+	// Args are queue declare arguments such as x-max-priority or x-queue-type
+	Args amqp.Table
+	// Bindings bind queue to exchanges, queue is bound to broker exchange
+	// with its name as routing key when nil
+	Bindings []*AMQPBinding
+}
+
+// AMQPBinding stores AMQP queue binding configuration
+type AMQPBinding struct {
+	// Exchange defaults to broker exchange when empty
+	Exchange string
+	// RoutingKey defaults to queue name when empty
+	RoutingKey string
+	Args       amqp.Table
 }
 
 // NewAMQPQueue creates new AMQPQueue
@@ -46,96 +70,305 @@ func NewAMQPQueue(name string) *AMQPQueue {
 	}
 }
 
+// NewAMQPPriorityQueue creates new AMQPQueue supporting message priorities up to maxPriority
+func NewAMQPPriorityQueue(name string, maxPriority uint8) *AMQPQueue {
+	queue := NewAMQPQueue(name)
+	queue.Args = amqp.Table{"x-max-priority": int32(maxPriority)}
+	return queue
+}
+
+// NewAMQPQuorumQueue creates new replicated quorum AMQPQueue.
+// Quorum queues are always durable and never auto-deleted.
+func NewAMQPQuorumQueue(name string) *AMQPQueue {
+	queue := NewAMQPQueue(name)
+	queue.Args = amqp.Table{"x-queue-type": "quorum"}
+	return queue
+}
+
 // AMQPCeleryBroker is RedisBroker for AMQP
 type AMQPCeleryBroker struct {
 	*amqp.Channel
 	Connection       *amqp.Connection
 	Exchange         *AMQPExchange
 	Queue            *AMQPQueue
-	consumingChannel <-chan amqp.Delivery
+	consumingChannel chan amqp.Delivery
 	Rate             int
+	// Queues lists queues consumed by workers, Queue is consumed when nil.
+	// Each queue gets its own consumer on the shared channel.
+	Queues       []*AMQPQueue
+	consumerTags map[string]string
+	queueLock    sync.Mutex
+	// consumerLock makes checking, starting and recording consumers one step
+	consumerLock sync.Mutex
+	// forwarders move deliveries of consumers to consuming channel until
+	// stopForwarding is closed, then they give deliveries back
+	forwarders     sync.WaitGroup
+	stopForwarding chan struct{}
+	// QueueConfigs are settings of queues which are published to but not consumed,
+	// keyed by queue name. Other unknown queues are declared by NewAMQPQueue.
+	QueueConfigs map[string]*AMQPQueue
+	// ExchangeConfigs are settings of exchanges queues are bound to, keyed by exchange name.
+	// Unknown exchanges other than Exchange are declared by NewAMQPExchange.
+	ExchangeConfigs map[string]*AMQPExchange
+	// AcksLate leaves deliveries unacknowledged until the worker has stored task result.
+	// Deliveries still unacknowledged when channel closes are requeued by the server.
+	AcksLate bool
+	// Host is dialed again when connection to AMQP server is lost
+	Host        string
+	channelLock sync.RWMutex
+	// DelayedDelivery publishes protocol v2 messages with future ETA to
+	// celery native delayed delivery queues, where server holds them until due.
+	// As in celery, only messages to topic exchanges are delayed by server,
+	// which receive due messages with delay digits prepended to routing key.
+	// Call CreateQueue after enabling it to bind consumed queues declared before.
+	DelayedDelivery bool
+	// DelayedQueueType is x-queue-type of delay queues, classic when empty
+	DelayedQueueType string
+	delayedConn      *amqp.Connection
+	delayedLock      sync.Mutex
+	// PublishConfirm publishes messages as mandatory and waits up to ConfirmTimeout
+	// for server to confirm them. Sends fail when message is nacked or returned.
+	PublishConfirm bool
+	ConfirmTimeout time.Duration
+	// PublishChannels is number of idle channels kept for publishing.
+	// Publishers never use consuming channel.
+	PublishChannels int
+	publishers      *amqpChannelPool
 }
 
-// NewAMQPConnection creates new AMQP channel
-func NewAMQPConnection(host string) (*amqp.Connection, *amqp.Channel) {
-	connection, err := amqp.Dial(host)
+// NewAMQPCeleryBroker creates new AMQPCeleryBroker
+func NewAMQPCeleryBroker(host string) (*AMQPCeleryBroker, error) {
+	conn, channel, err := NewAMQPConnection(host)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
-
-	channel, err := connection.Channel()
+	broker, err := newAMQPCeleryBroker(conn, channel, host)
 	if err != nil {
-		panic(err)
+		conn.Close()
+		return nil, err
 	}
-	return connection, channel
+	return broker, nil
 }
 
-// NewAMQPCeleryBroker creates new AMQPCeleryBroker
-func NewAMQPCeleryBroker(host string) *AMQPCeleryBroker {
-	return NewAMQPCeleryBrokerByConnAndChannel(NewAMQPConnection(host))
+// NewAMQPCeleryBrokerByConnAndChannel creates new AMQPCeleryBroker using AMQP conn and channel.
+// Closed channel is opened again, but lost connection is not redialed without Host.
+func NewAMQPCeleryBrokerByConnAndChannel(conn *amqp.Connection, channel *amqp.Channel) (*AMQPCeleryBroker, error) {
+	return newAMQPCeleryBroker(conn, channel, "")
 }
 
-// NewAMQPCeleryBrokerByConnAndChannel creates new AMQPCeleryBroker using AMQP conn and channel
-func NewAMQPCeleryBrokerByConnAndChannel(conn *amqp.Connection, channel *amqp.Channel) *AMQPCeleryBroker {
+// newAMQPCeleryBroker creates new AMQPCeleryBroker and supervises its connection
+func newAMQPCeleryBroker(conn *amqp.Connection, channel *amqp.Channel, host string) (*AMQPCeleryBroker, error) {
 	broker := &AMQPCeleryBroker{
 		Channel:    channel,
 		Connection: conn,
 		Exchange:   NewAMQPExchange("default"),
 		Queue:      NewAMQPQueue("celery"),
 		Rate:       4,
+		Host:       host,
+
+		ConfirmTimeout:  5 * time.Second,
+		PublishChannels: 8,
+	}
+	if err := broker.setup(); err != nil {
+		return nil, err
+	}
+	go superviseConnection(conn, channel, broker.reconnect, broker.reopen)
+	return broker, nil
+}
+
+// setup declares exchange and queue and starts consuming
+func (b *AMQPCeleryBroker) setup() error {
+	if err := b.CreateExchange(); err != nil {
+		return err
 	}
-	if err := broker.CreateExchange(); err != nil {
-		panic(err)
+	if err := b.CreateQueue(); err != nil {
+		return err
+	}
+	channel, err := b.channel()
+	if err != nil {
+		return err
+	}
+	if err := channel.Qos(b.Rate, 0, false); err != nil {
+		return err
+	}
+	return b.StartConsumingChannel()
+}
+
+// reconnect dials Host again and restores exchange, queue and consumers
+func (b *AMQPCeleryBroker) reconnect() (*amqp.Connection, *amqp.Channel, error) {
+	if b.Host == "" {
+		return nil, nil, errAMQPNoHost
+	}
+	conn, err := amqp.Dial(b.Host)
+	if err != nil {
+		return nil, nil, err
+	}
+	channel, err := b.reopen(conn)
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	return conn, channel, nil
+}
+
+// reopen opens new channel on conn and restores exchange, queue and consumers
+func (b *AMQPCeleryBroker) reopen(conn *amqp.Connection) (*amqp.Channel, error) {
+	channel, err := conn.Channel()
+	if err != nil {
+		return nil, err
 	}
-	if err := broker.CreateQueue(); err != nil {
-		panic(err)
+	b.channelLock.Lock()
+	b.Connection, b.Channel = conn, channel
+	b.channelLock.Unlock()
+	if err := b.setup(); err != nil {
+		channel.Close()
+		return nil, err
 	}
-	if err := broker.Qos(broker.Rate, 0, false); err != nil {
-		panic(err)
+	return channel, nil
+}
+
+// channel returns current AMQP channel or ErrAMQPDisconnected while it is down
+func (b *AMQPCeleryBroker) channel() (*amqp.Channel, error) {
+	b.channelLock.RLock()
+	channel := b.Channel
+	b.channelLock.RUnlock()
+	if channel == nil || channel.IsClosed() {
+		return nil, ErrAMQPDisconnected
 	}
-	if err := broker.StartConsumingChannel(); err != nil {
-		panic(err)
+	return channel, nil
+}
+
+// deliveries returns channel merging deliveries of every consumer
+func (b *AMQPCeleryBroker) deliveries() <-chan amqp.Delivery {
+	b.channelLock.RLock()
+	defer b.channelLock.RUnlock()
+	return b.consumingChannel
+}
+
+// consumedQueues returns queues workers consume from
+func (b *AMQPCeleryBroker) consumedQueues() []*AMQPQueue {
+	b.queueLock.Lock()
+	defer b.queueLock.Unlock()
+	if b.Queues == nil {
+		return []*AMQPQueue{b.Queue}
 	}
-	return broker
+	return append([]*AMQPQueue(nil), b.Queues...)
 }
 
-// StartConsumingChannel spawns receiving channel on AMQP queue
+// StartConsumingChannel spawns receiving channel on AMQP queues
 func (b *AMQPCeleryBroker) StartConsumingChannel() error {
-	channel, err := b.Consume(b.Queue.Name, "", false, false, false, false, nil)
+	b.consumerLock.Lock()
+	defer b.consumerLock.Unlock()
+	for _, queue := range b.consumedQueues() {
+		if err := b.consume(queue.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// consume starts consumer on queue and forwards its deliveries to consuming channel
+func (b *AMQPCeleryBroker) consume(queueName string) error {
+	channel, err := b.channel()
 	if err != nil {
 		return err
 	}
-	b.consumingChannel = channel
+	consumerTag := fmt.Sprintf("gocelery-%s-%s", queueName, uuid.New().String())
+	deliveries, err := channel.Consume(queueName, consumerTag, false, false, false, false, nil)
+	if err != nil {
+		return err
+	}
+	b.queueLock.Lock()
+	if b.consumerTags == nil {
+		b.consumerTags = map[string]string{}
+	}
+	b.consumerTags[queueName] = consumerTag
+	b.queueLock.Unlock()
+	b.channelLock.Lock()
+	if b.consumingChannel == nil {
+		b.consumingChannel = make(chan amqp.Delivery)
+	}
+	if b.stopForwarding == nil {
+		b.stopForwarding = make(chan struct{})
+	}
+	consumingChannel, stop := b.consumingChannel, b.stopForwarding
+	b.channelLock.Unlock()
+	b.forwarders.Add(1)
+	go func() {
+		defer b.forwarders.Done()
+		for delivery := range deliveries {
+			select {
+			case consumingChannel <- delivery:
+			case <-stop:
+				// no worker takes it any more, give it back to the queue
+				if err := delivery.Nack(false, true); err != nil {
+					log.Printf("failed to requeue prefetched message %s: %+v", delivery.MessageId, err)
+				}
+			}
+		}
+	}()
 	return nil
 }
 
+// StopConsuming cancels consumers and gives messages they prefetched
+// and workers did not take back to their queues
+func (b *AMQPCeleryBroker) StopConsuming() error {
+	b.consumerLock.Lock()
+	defer b.consumerLock.Unlock()
+	b.queueLock.Lock()
+	consumerTags := b.consumerTags
+	b.consumerTags = nil
+	b.queueLock.Unlock()
+	b.channelLock.Lock()
+	stop := b.stopForwarding
+	b.stopForwarding = nil
+	b.channelLock.Unlock()
+	if stop != nil {
+		close(stop)
+	}
+	// consumers of closed channel are gone with it and server requeues their messages
+	if channel, err := b.channel(); err == nil {
+		for _, consumerTag := range consumerTags {
+			if err := channel.Cancel(consumerTag, false); err != nil {
+				return err
+			}
+		}
+	}
+	// cancelled consumers close their deliveries once prefetched ones are forwarded
+	b.forwarders.Wait()
+	return nil
+}
+
+// receive takes delivery from consuming channel without blocking
+func (b *AMQPCeleryBroker) receive() (amqp.Delivery, error) {
+	select {
+	case delivery, ok := <-b.deliveries():
+		if !ok {
+			return delivery, ErrAMQPDisconnected
+		}
+		return delivery, nil
+	default:
+		return amqp.Delivery{}, fmt.Errorf("consuming channel is empty")
+	}
+}
+
 // SendCeleryMessage sends CeleryMessage to broker
 func (b *AMQPCeleryBroker) SendCeleryMessage(message *CeleryMessage) error {
-	taskMessage := message.GetTaskMessage()
-	queueName := "celery"
-	_, err := b.QueueDeclare(
-		queueName, // name
-		true,      // durable
-		false,     // autoDelete
-		false,     // exclusive
-		false,     // noWait
-		nil,       // args
-	)
+	pool, err := b.publisherPool()
 	if err != nil {
 		return err
 	}
-	err = b.ExchangeDeclare(
-		"default",
-		"direct",
-		true,
-		true,
-		false,
-		false,
-		nil,
-	)
+	publisher, err := pool.get()
 	if err != nil {
 		return err
 	}
+	defer pool.put(publisher)
+	taskMessage := message.GetTaskMessage()
+	queue := b.queueConfig(b.Queue.Name)
+	queueName := queue.Name
+	if err := b.declareRoute(pool, publisher.Channel, b.Exchange, queue); err != nil {
+		return err
+	}
 
 	resBytes, err := json.Marshal(taskMessage)
 	if err != nil {
@@ -149,54 +382,336 @@ func (b *AMQPCeleryBroker) SendCeleryMessage(message *CeleryMessage) error {
 		Body:         resBytes,
 	}
 
-	return b.Publish(
-		"",
-		queueName,
-		false,
-		false,
-		publishMessage,
-	)
+	return b.publish(publisher, "", queueName, publishMessage)
 }
 
 // GetTaskMessage retrieves task message from AMQP queue
 func (b *AMQPCeleryBroker) GetTaskMessage() (*TaskMessage, error) {
-	select {
-	case delivery := <-b.consumingChannel:
-		deliveryAck(delivery)
-		var taskMessage TaskMessage
-		if err := json.Unmarshal(delivery.Body, &taskMessage); err != nil {
-			return nil, err
-		}
-		return &taskMessage, nil
-	default:
-		return nil, fmt.Errorf("consuming channel is empty")
+	delivery, err := b.receive()
+	if err != nil {
+		return nil, err
 	}
+	deliveryAck(delivery)
+	var taskMessage TaskMessage
+	if err := json.Unmarshal(delivery.Body, &taskMessage); err != nil {
+		return nil, err
+	}
+	return &taskMessage, nil
 }
 
 // CreateExchange declares AMQP exchange with stored configuration
 func (b *AMQPCeleryBroker) CreateExchange() error {
-	return b.ExchangeDeclare(
-		b.Exchange.Name,
-		b.Exchange.Type,
-		b.Exchange.Durable,
-		b.Exchange.AutoDelete,
+	channel, err := b.channel()
+	if err != nil {
+		return err
+	}
+	return declareExchange(channel, b.Exchange)
+}
+
+// declareExchange declares AMQP exchange with its configuration
+func declareExchange(channel *amqp.Channel, exchange *AMQPExchange) error {
+	return channel.ExchangeDeclare(
+		exchange.Name,
+		exchange.Type,
+		exchange.Durable,
+		exchange.AutoDelete,
 		false,
 		false,
-		nil,
+		exchange.Args,
 	)
 }
 
-// CreateQueue declares AMQP Queue with stored configuration
+// CreateQueue declares AMQP Queue and consumed Queues with stored configuration
 func (b *AMQPCeleryBroker) CreateQueue() error {
-	_, err := b.QueueDeclare(
-		b.Queue.Name,
-		b.Queue.Durable,
-		b.Queue.AutoDelete,
+	channel, err := b.channel()
+	if err != nil {
+		return err
+	}
+	for _, queue := range append([]*AMQPQueue{b.Queue}, b.consumedQueues()...) {
+		if err := b.declareQueue(channel, queue, b.Exchange.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// queueConfig returns configuration of queue with given name,
+// consumed queues and QueueConfigs are looked up before falling back to defaults
+func (b *AMQPCeleryBroker) queueConfig(queueName string) *AMQPQueue {
+	if b.Queue != nil && b.Queue.Name == queueName {
+		return b.Queue
+	}
+	for _, queue := range b.consumedQueues() {
+		if queue.Name == queueName {
+			return queue
+		}
+	}
+	if queue, ok := b.QueueConfigs[queueName]; ok {
+		return queue
+	}
+	return NewAMQPQueue(queueName)
+}
+
+// exchangeConfig returns configuration of exchange with given name
+func (b *AMQPCeleryBroker) exchangeConfig(exchangeName string) *AMQPExchange {
+	if b.Exchange != nil && b.Exchange.Name == exchangeName {
+		return b.Exchange
+	}
+	if exchange, ok := b.ExchangeConfigs[exchangeName]; ok {
+		return exchange
+	}
+	return NewAMQPExchange(exchangeName)
+}
+
+// declareQueue declares queue with its arguments and bindings.
+// Queue without bindings is bound to exchangeName by its name.
+func (b *AMQPCeleryBroker) declareQueue(channel *amqp.Channel, queue *AMQPQueue, exchangeName string) error {
+	_, err := channel.QueueDeclare(
+		queue.Name,
+		queue.Durable,
+		queue.AutoDelete,
 		false,
 		false,
-		nil,
+		queue.Args,
 	)
-	return err
+	if err != nil {
+		return err
+	}
+	if queue.Bindings == nil {
+		if err := channel.QueueBind(queue.Name, queue.Name, exchangeName, false, nil); err != nil {
+			return err
+		}
+		if b.DelayedDelivery {
+			return b.bindDelayedDelivery(channel, b.exchangeConfig(exchangeName), queue.Name)
+		}
+		return nil
+	}
+	for _, binding := range queue.Bindings {
+		bindingExchange, routingKey := b.bindingRoute(queue, binding)
+		if bindingExchange != b.Exchange.Name {
+			if err := declareExchange(channel, b.exchangeConfig(bindingExchange)); err != nil {
+				return err
+			}
+		}
+		if err := channel.QueueBind(queue.Name, routingKey, bindingExchange, false, binding.Args); err != nil {
+			return err
+		}
+		if b.DelayedDelivery {
+			if err := b.bindDelayedDelivery(channel, b.exchangeConfig(bindingExchange), routingKey); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// bindingRoute returns exchange and routing key of binding with defaults applied
+func (b *AMQPCeleryBroker) bindingRoute(queue *AMQPQueue, binding *AMQPBinding) (string, string) {
+	exchangeName := b.Exchange.Name
+	if binding.Exchange != "" {
+		exchangeName = binding.Exchange
+	}
+	routingKey := queue.Name
+	if binding.RoutingKey != "" {
+		routingKey = binding.RoutingKey
+	}
+	return exchangeName, routingKey
+}
+
+// publishRoute returns exchange and routing key messages for queue are published with.
+// Binding patterns with wildcards are not routing keys, so queue bound only by
+// patterns is published to through default exchange by its name.
+func (b *AMQPCeleryBroker) publishRoute(queue *AMQPQueue) (string, string) {
+	if len(queue.Bindings) == 0 {
+		return b.Exchange.Name, queue.Name
+	}
+	for _, binding := range queue.Bindings {
+		exchangeName, routingKey := b.bindingRoute(queue, binding)
+		if b.exchangeConfig(exchangeName).Type == "fanout" {
+			// fanout exchange ignores routing key
+			return exchangeName, queue.Name
+		}
+		if !strings.ContainsAny(routingKey, "*#") {
+			return exchangeName, routingKey
+		}
+	}
+	return "", queue.Name
+}
+
+// AddConsumer declares queue and starts consuming it
+func (b *AMQPCeleryBroker) AddConsumer(queueName string) error {
+	b.consumerLock.Lock()
+	defer b.consumerLock.Unlock()
+	for _, queue := range b.consumedQueues() {
+		if queue.Name == queueName {
+			return nil
+		}
+	}
+	channel, err := b.channel()
+	if err != nil {
+		return err
+	}
+	queue := b.queueConfig(queueName)
+	if err := b.declareQueue(channel, queue, b.Exchange.Name); err != nil {
+		return err
+	}
+	if err := b.consume(queueName); err != nil {
+		return err
+	}
+	b.queueLock.Lock()
+	if b.Queues == nil {
+		b.Queues = []*AMQPQueue{b.Queue}
+	}
+	b.Queues = append(b.Queues, queue)
+	b.queueLock.Unlock()
+	return nil
+}
+
+// CancelConsumer cancels consumer of queue
+func (b *AMQPCeleryBroker) CancelConsumer(queueName string) error {
+	b.consumerLock.Lock()
+	defer b.consumerLock.Unlock()
+	b.queueLock.Lock()
+	if b.Queues == nil {
+		b.Queues = []*AMQPQueue{b.Queue}
+	}
+	queues := make([]*AMQPQueue, 0, len(b.Queues))
+	for _, queue := range b.Queues {
+		if queue.Name != queueName {
+			queues = append(queues, queue)
+		}
+	}
+	b.Queues = queues
+	consumerTag, ok := b.consumerTags[queueName]
+	delete(b.consumerTags, queueName)
+	b.queueLock.Unlock()
+	if !ok {
+		return nil
+	}
+	channel, err := b.channel()
+	if err != nil {
+		return err
+	}
+	return channel.Cancel(consumerTag, false)
+}
+
+// ConsumingQueues returns names of consumed queues
+func (b *AMQPCeleryBroker) ConsumingQueues() []string {
+	queues := b.consumedQueues()
+	names := make([]string, len(queues))
+	for i, queue := range queues {
+		names[i] = queue.Name
+	}
+	return names
+}
+
+// ConsumeControl consumes celery remote control broadcasts from
+// worker pidbox queue bound to celery.pidbox fanout exchange
+func (b *AMQPCeleryBroker) ConsumeControl(ctx context.Context, hostname string) (<-chan *ControlMessage, error) {
+	deliveries, err := b.consumeControl(hostname)
+	if err != nil {
+		return nil, err
+	}
+	messages := make(chan *ControlMessage)
+	go func() {
+		defer close(messages)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case delivery, ok := <-deliveries:
+				if !ok {
+					// consumer is gone with the channel, wait for reconnection
+					deliveries = nil
+					select {
+					case <-ctx.Done():
+						return
+					case <-time.After(time.Second):
+					}
+					if deliveries, err = b.consumeControl(hostname); err != nil {
+						deliveries = closedDeliveries
+					}
+					continue
+				}
+				message, err := decodeControlMessage(delivery.Body, "")
+				if err != nil {
+					log.Printf("failed to decode control message: %+v", err)
+					continue
+				}
+				select {
+				case messages <- message:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return messages, nil
+}
+
+// ReplyControl publishes reply to control command to reply exchange of its caller
+func (b *AMQPCeleryBroker) ReplyControl(message *ControlMessage, hostname string, reply map[string]interface{}) error {
+	if message.ReplyTo == nil {
+		return nil
+	}
+	body, err := controlReplyBody(hostname, reply)
+	if err != nil {
+		return err
+	}
+	pool, err := b.publisherPool()
+	if err != nil {
+		return err
+	}
+	publisher, err := pool.get()
+	if err != nil {
+		return err
+	}
+	defer pool.put(publisher)
+	exchange := message.ReplyTo.Exchange
+	err = pool.declare("exchange:"+exchange, func() error {
+		// celery declares reply exchange as transient direct exchange
+		return publisher.ExchangeDeclare(exchange, "direct", false, false, false, false, nil)
+	})
+	if err != nil {
+		return err
+	}
+	return publisher.Publish(exchange, message.ReplyTo.RoutingKey, false, false, amqp.Publishing{
+		Headers:         amqp.Table{"ticket": message.Ticket},
+		ContentType:     "application/json",
+		ContentEncoding: "utf-8",
+		CorrelationId:   message.Ticket,
+		Body:            body,
+	})
+}
+
+// closedDeliveries stands in for consumer which could not be started
+var closedDeliveries = func() chan amqp.Delivery {
+	deliveries := make(chan amqp.Delivery)
+	close(deliveries)
+	return deliveries
+}()
+
+// consumeControl declares pidbox exchange and queue the way celery does and consumes the queue
+func (b *AMQPCeleryBroker) consumeControl(hostname string) (<-chan amqp.Delivery, error) {
+	channel, err := b.channel()
+	if err != nil {
+		return nil, err
+	}
+	if err := channel.ExchangeDeclare("celery.pidbox", "fanout", false, false, false, false, nil); err != nil {
+		return nil, err
+	}
+	queueName := fmt.Sprintf("%s.celery.pidbox", hostname)
+	args := amqp.Table{
+		"x-message-ttl": int32(300000),
+		"x-expires":     int32(10000),
+	}
+	if _, err := channel.QueueDeclare(queueName, false, true, false, false, args); err != nil {
+		return nil, err
+	}
+	if err := channel.QueueBind(queueName, "", "celery.pidbox", false, nil); err != nil {
+		return nil, err
+	}
+	return channel.Consume(queueName, "", true, false, false, false, nil)
 }
 
 // SendCeleryMessageV2 send celery message for celery protocol v2
@@ -205,71 +720,237 @@ func (b *AMQPCeleryBroker) SendCeleryMessageV2(message *CeleryMessageV2) error {
 	if rk := message.Properties.DeliveryInfo.RoutingKey; rk != "" {
 		queueName = rk
 	}
-	exchangeName := b.Exchange.Name
+	queue := b.queueConfig(queueName)
+	exchangeName, routingKey := b.Exchange.Name, queueName
 	if ex := message.Properties.DeliveryInfo.Exchange; ex != "" {
 		exchangeName = ex
+	} else {
+		// route through queue binding when exchange is not given explicitly
+		exchangeName, routingKey = b.publishRoute(queue)
 	}
-	_, err := b.QueueDeclare(
-		queueName, // name
-		true,      // durable
-		false,     // autoDelete
-		false,     // exclusive
-		false,     // noWait
-		nil,       // args
-	)
+	pool, err := b.publisherPool()
 	if err != nil {
 		return err
 	}
-	err = b.ExchangeDeclare(
-		exchangeName,
-		"direct",
-		true,
-		true,
-		false,
-		false,
-		nil,
-	)
+	publisher, err := pool.get()
+	if err != nil {
+		return err
+	}
+	defer pool.put(publisher)
+	if err := b.declareRoute(pool, publisher.Channel, b.exchangeConfig(exchangeName), queue); err != nil {
+		return err
+	}
+	// direct exchanges are not bound to delivery exchange, worker holds their messages instead
+	if eta, ok := parseETA(message.Headers.Eta); ok && b.DelayedDelivery && b.exchangeConfig(exchangeName).Type != "direct" {
+		if countdown := int64(time.Until(eta) / time.Second); countdown > 0 {
+			// worker holds message for the rest of the second it arrives early
+			exchangeName = amqpDelayedName(amqpDelayedLevels - 1)
+			routingKey = amqpDelayedRoutingKey(countdown, routingKey)
+		}
+	}
+
+	publishMessage, err := newAMQPPublishingV2(message)
 	if err != nil {
 		return err
 	}
 
-	resBytes, err := json.Marshal(message)
+	return b.publish(publisher, exchangeName, routingKey, publishMessage)
+}
+
+// publisherPool returns pool of publishing channels of current connection
+func (b *AMQPCeleryBroker) publisherPool() (*amqpChannelPool, error) {
+	b.channelLock.Lock()
+	defer b.channelLock.Unlock()
+	if b.Connection == nil || b.Connection.IsClosed() {
+		return nil, ErrAMQPDisconnected
+	}
+	if b.publishers == nil || b.publishers.conn != b.Connection {
+		// declarations are cached per connection and made again after reconnection
+		b.publishers = newAMQPChannelPool(b.Connection, b.PublishChannels)
+	}
+	return b.publishers, nil
+}
+
+// declareRoute declares exchange and queue bound to it once per connection
+func (b *AMQPCeleryBroker) declareRoute(pool *amqpChannelPool, channel *amqp.Channel, exchange *AMQPExchange, queue *AMQPQueue) error {
+	// default exchange exists on every server and cannot be declared
+	if exchange.Name != "" {
+		err := pool.declare("exchange:"+exchange.Name, func() error {
+			return declareExchange(channel, exchange)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return pool.declare("queue:"+queue.Name+":"+exchange.Name, func() error {
+		return b.declareQueue(channel, queue, exchange.Name)
+	})
+}
+
+// publish publishes message, waiting for its confirmation in confirm mode
+func (b *AMQPCeleryBroker) publish(publisher *amqpPublisher, exchangeName string, routingKey string, message amqp.Publishing) error {
+	if !b.PublishConfirm {
+		return publisher.Publish(exchangeName, routingKey, false, false, message)
+	}
+	if publisher.returns == nil {
+		if err := publisher.Confirm(false); err != nil {
+			return err
+		}
+		publisher.returns = publisher.NotifyReturn(make(chan amqp.Return, 1))
+		publisher.closed = publisher.NotifyClose(make(chan *amqp.Error, 1))
+	}
+	// returns are told apart by message id, so late return of message
+	// which was not confirmed in time is not taken for return of this one
+	if message.MessageId == "" {
+		message.MessageId = uuid.New().String()
+	}
+	confirmation, err := publisher.PublishWithDeferredConfirm(exchangeName, routingKey, true, false, message)
+	if err != nil {
+		return err
+	}
+	timeout := b.ConfirmTimeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	returned, err := publisher.waitConfirm(confirmation, message.MessageId, timeout)
 	if err != nil {
 		return err
 	}
+	if returned != nil {
+		return fmt.Errorf("%w: %d %s (exchange %q, routing key %q)",
+			ErrAMQPPublishReturned, returned.ReplyCode, returned.ReplyText, returned.Exchange, returned.RoutingKey)
+	}
+	if !confirmation.Acked() {
+		return ErrAMQPPublishNacked
+	}
+	return nil
+}
 
-	publishMessage := amqp.Publishing{
+// GetCeleryMessageV2 retrieves celery message v2 from AMQP queue
+func (b *AMQPCeleryBroker) GetCeleryMessageV2() (*CeleryMessageV2, error) {
+	delivery, err := b.receive()
+	if err != nil {
+		return nil, err
+	}
+	deliveryAck(delivery)
+	return newCeleryMessageV2FromDelivery(delivery)
+}
+
+// newAMQPPublishingV2 maps celery message v2 to AMQP message the way kombu does:
+// task headers go to AMQP headers and body is sent as raw json
+func newAMQPPublishingV2(message *CeleryMessageV2) (amqp.Publishing, error) {
+	body := []byte(message.Body)
+	if message.Properties.BodyEncoding == "base64" {
+		decoded, err := base64.StdEncoding.DecodeString(message.Body)
+		if err != nil {
+			return amqp.Publishing{}, err
+		}
+		body = decoded
+	}
+	headers := message.Headers
+	return amqp.Publishing{
+		Headers: amqp.Table{
+			"lang":      headers.Lang,
+			"task":      headers.Task,
+			"id":        headers.ID,
+			"root_id":   headers.RootID,
+			"parent_id": nilIfEmpty(headers.ParentID),
+			"group":     nilIfEmpty(headers.Group),
+			"expires":   headers.Expires,
+			"shadow":    headers.Shadow,
+			"retries":   int32(headers.Retries),
+			"eta":       headers.Eta,
+			"argsrepr":  headers.Argsrepr,
+			"timelimit": []interface{}{headers.TimeLimit[0], headers.TimeLimit[1]},
+			"origin":    headers.Origin,
+		},
 		DeliveryMode:    uint8(message.Properties.DeliveryMode),
 		Priority:        uint8(message.Properties.Priority),
 		Timestamp:       time.Now(),
 		ContentType:     message.ContentType,
 		ContentEncoding: message.ContentEncoding,
-		Body:            resBytes,
+		Body:            body,
 		CorrelationId:   message.Properties.CorrelationID,
 		ReplyTo:         message.Properties.ReplyTo,
 		MessageId:       message.Properties.DeliveryTag,
+	}, nil
+}
+
+// newCeleryEnvelopeFromDelivery maps AMQP message published by kombu to celery envelope.
+// Protocol v2 carries task headers in AMQP headers, protocol v1 only has json body.
+func newCeleryEnvelopeFromDelivery(delivery amqp.Delivery) *CeleryEnvelope {
+	if _, ok := delivery.Headers["task"]; !ok {
+		// json envelope sent by older gocelery
+		var envelope CeleryEnvelope
+		if err := json.Unmarshal(delivery.Body, &envelope); err == nil && envelope.IsV2() {
+			return &envelope
+		}
 	}
+	contentEncoding := delivery.ContentEncoding
+	if contentEncoding == "" {
+		contentEncoding = "utf-8"
+	}
+	return &CeleryEnvelope{
+		Body:            base64.StdEncoding.EncodeToString(delivery.Body),
+		Headers:         delivery.Headers,
+		ContentType:     delivery.ContentType,
+		ContentEncoding: contentEncoding,
+		Properties: CeleryPropertiesV2{
+			Priority:      int(delivery.Priority),
+			BodyEncoding:  "base64",
+			CorrelationID: delivery.CorrelationId,
+			ReplyTo:       delivery.ReplyTo,
+			DeliveryInfo: CeleryDeliveryInfoV2{
+				RoutingKey: delivery.RoutingKey,
+				Exchange:   delivery.Exchange,
+			},
+			DeliveryMode: int(delivery.DeliveryMode),
+			DeliveryTag:  strconv.FormatUint(delivery.DeliveryTag, 10),
+		},
+	}
+}
 
-	return b.Publish(
-		exchangeName,
-		queueName,
-		false,
-		false,
-		publishMessage,
-	)
+// newCeleryMessageV2FromDelivery maps AMQP message published by kombu back to celery message v2
+func newCeleryMessageV2FromDelivery(delivery amqp.Delivery) (*CeleryMessageV2, error) {
+	return newCeleryEnvelopeFromDelivery(delivery).GetCeleryMessageV2()
 }
 
-// GetCeleryMessageV2 retrieves celery message v2 from AMQP queue
-func (b *AMQPCeleryBroker) GetCeleryMessageV2() (*CeleryMessageV2, error) {
-	select {
-	case delivery := <-b.consumingChannel:
+// nilIfEmpty maps empty header to None as sent by python client
+func nilIfEmpty(value string) interface{} {
+	if value == "" {
+		return nil
+	}
+	return value
+}
+
+// GetCeleryEnvelope retrieves message of either protocol version from AMQP queue.
+// With AcksLate delivery is left unacknowledged until the worker settles it.
+func (b *AMQPCeleryBroker) GetCeleryEnvelope() (*CeleryEnvelope, error) {
+	delivery, err := b.receive()
+	if err != nil {
+		return nil, err
+	}
+	if !b.AcksLate {
 		deliveryAck(delivery)
-		var celeryMessage CeleryMessageV2
-		if err := json.Unmarshal(delivery.Body, &celeryMessage); err != nil {
-			return nil, err
-		}
-		return &celeryMessage, nil
-	default:
-		return nil, fmt.Errorf("consuming channel is empty")
 	}
+	envelope := newCeleryEnvelopeFromDelivery(delivery)
+	if b.AcksLate {
+		envelope.Delivery = &amqpDelivery{delivery}
+	}
+	return envelope, nil
+}
+
+// amqpDelivery is AMQP delivery left unacknowledged on receive
+type amqpDelivery struct {
+	amqp.Delivery
+}
+
+// Ack acknowledges delivery
+func (d *amqpDelivery) Ack() error {
+	return d.Delivery.Ack(false)
+}
+
+// Nack rejects delivery, requeueing it if requested
+func (d *amqpDelivery) Nack(requeue bool) error {
+	return d.Delivery.Nack(false, requeue)
 }
@@ -0,0 +1,117 @@
+// Copyright (c) 2019 Sick Yoon
+// This file is part of gocelery which is released under MIT license.
+// See file LICENSE for full license details.
+
+package gocelery
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// Native delayed delivery topology shared with celery 5.5.
+// Countdown is encoded in routing key as binary digits, one per level.
+// Level n queue holds message for 2^n seconds when its digit is 1
+// and dead-letters it to level n-1, digit 0 skips the level through
+// exchange to exchange binding. Level 0 ends in delivery exchange
+// which routes message to destination queue by the rest of routing key.
+const (
+	amqpDelayedDeliveryExchange = "celery_delayed_delivery"
+	amqpDelayedLevels           = 28
+	amqpMaxDelay                = 1<<amqpDelayedLevels - 1
+)
+
+// amqpDelayedName returns name of exchange and queue of delay level
+func amqpDelayedName(level int) string {
+	return fmt.Sprintf("celery_delayed_%d", level)
+}
+
+// amqpDelayedRoutingKey encodes countdown in seconds into routing key to destination routingKey
+func amqpDelayedRoutingKey(countdown int64, routingKey string) string {
+	if countdown < 0 {
+		countdown = 0
+	} else if countdown > amqpMaxDelay {
+		countdown = amqpMaxDelay
+	}
+	binary := fmt.Sprintf("%0*b", amqpDelayedLevels, countdown)
+	return strings.Join(strings.Split(binary, ""), ".") + "." + routingKey
+}
+
+// declareDelayedDelivery declares delay levels and delivery exchange once per connection
+func (b *AMQPCeleryBroker) declareDelayedDelivery(channel *amqp.Channel) error {
+	b.channelLock.RLock()
+	conn := b.Connection
+	b.channelLock.RUnlock()
+	b.delayedLock.Lock()
+	defer b.delayedLock.Unlock()
+	if conn != nil && b.delayedConn == conn {
+		return nil
+	}
+	queueType := b.DelayedQueueType
+	if queueType == "" {
+		queueType = "classic"
+	}
+	routingKey := "1.#"
+	for level := amqpDelayedLevels - 1; level >= 0; level-- {
+		name := amqpDelayedName(level)
+		if err := channel.ExchangeDeclare(name, "topic", true, false, false, false, nil); err != nil {
+			return err
+		}
+		deadLetterExchange := amqpDelayedDeliveryExchange
+		if level > 0 {
+			deadLetterExchange = amqpDelayedName(level - 1)
+		}
+		args := amqp.Table{
+			"x-queue-type":           queueType,
+			"x-overflow":             "reject-publish",
+			"x-message-ttl":          int64(time.Duration(1<<level) * time.Second / time.Millisecond),
+			"x-dead-letter-exchange": deadLetterExchange,
+		}
+		if queueType == "quorum" {
+			args["x-dead-letter-strategy"] = "at-least-once"
+		}
+		if _, err := channel.QueueDeclare(name, true, false, false, false, args); err != nil {
+			return err
+		}
+		if err := channel.QueueBind(name, routingKey, name, false, nil); err != nil {
+			return err
+		}
+		routingKey = "*." + routingKey
+	}
+	routingKey = "0.#"
+	for level := amqpDelayedLevels - 1; level > 0; level-- {
+		if err := channel.ExchangeBind(amqpDelayedName(level-1), routingKey, amqpDelayedName(level), false, nil); err != nil {
+			return err
+		}
+		routingKey = "*." + routingKey
+	}
+	if err := channel.ExchangeDeclare(amqpDelayedDeliveryExchange, "topic", true, false, false, false, nil); err != nil {
+		return err
+	}
+	if err := channel.ExchangeBind(amqpDelayedDeliveryExchange, routingKey, amqpDelayedName(0), false, nil); err != nil {
+		return err
+	}
+	b.delayedConn = conn
+	return nil
+}
+
+// bindDelayedDelivery routes due messages with routingKey from delivery exchange to exchange
+// of queue like kombu bind_queue_to_native_delayed_delivery_exchange
+func (b *AMQPCeleryBroker) bindDelayedDelivery(channel *amqp.Channel, exchange *AMQPExchange, routingKey string) error {
+	if exchange.Name == "" {
+		// default exchange can not be bound to other exchanges
+		return nil
+	}
+	if exchange.Type == "direct" {
+		log.Printf("exchange %s is a direct exchange not supported by native delayed delivery, worker holds its ETA tasks until due", exchange.Name)
+		return nil
+	}
+	if err := b.declareDelayedDelivery(channel); err != nil {
+		return err
+	}
+	return channel.ExchangeBind(exchange.Name, "#."+routingKey, amqpDelayedDeliveryExchange, false, nil)
+}
@@ -0,0 +1,144 @@
+// Copyright (c) 2019 Sick Yoon
+// This file is part of gocelery which is released under MIT license.
+// See file LICENSE for full license details.
+
+package gocelery
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// amqpPublisher is channel used only for publishing
+type amqpPublisher struct {
+	*amqp.Channel
+	// returns receives returned mandatory messages once channel is in confirm mode
+	returns chan amqp.Return
+	// closed receives error channel was closed with once it is in confirm mode
+	closed chan *amqp.Error
+}
+
+// waitConfirm waits up to timeout for confirmation of message with messageID.
+// It returns return of the message when server could not route it, returns
+// of other messages are dropped. Error is returned when confirmation does not
+// come in time or channel is closed before it does.
+func (p *amqpPublisher) waitConfirm(confirmation *amqp.DeferredConfirmation, messageID string, timeout time.Duration) (*amqp.Return, error) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	var returned *amqp.Return
+	match := func(r amqp.Return) {
+		if r.MessageId == messageID {
+			returned = &r
+		}
+	}
+	returns := p.returns
+	for waiting := true; waiting; {
+		select {
+		case r, ok := <-returns:
+			if !ok {
+				returns = nil
+				continue
+			}
+			match(r)
+		case <-confirmation.Done():
+			waiting = false
+		case <-timer.C:
+			return nil, fmt.Errorf("%w after %v", ErrAMQPConfirmTimeout, timeout)
+		}
+	}
+	// server sends return before ack of the same message
+	for draining := returns != nil; draining; {
+		select {
+		case r, ok := <-returns:
+			if ok {
+				match(r)
+			} else {
+				draining = false
+			}
+		default:
+			draining = false
+		}
+	}
+	if !confirmation.Acked() && p.IsClosed() {
+		select {
+		case closeErr := <-p.closed:
+			if closeErr != nil {
+				return nil, fmt.Errorf("%w: %v", ErrAMQPDisconnected, closeErr)
+			}
+		default:
+		}
+		return nil, ErrAMQPDisconnected
+	}
+	return returned, nil
+}
+
+// amqpChannelPool keeps publishing channels of a connection together
+// with exchanges and queues already declared on the connection
+type amqpChannelPool struct {
+	conn *amqp.Connection
+	idle chan *amqpPublisher
+
+	declareLock sync.Mutex
+	declared    map[string]bool
+}
+
+// newAMQPChannelPool creates pool keeping up to size idle channels of conn
+func newAMQPChannelPool(conn *amqp.Connection, size int) *amqpChannelPool {
+	if size <= 0 {
+		size = 1
+	}
+	return &amqpChannelPool{
+		conn:     conn,
+		idle:     make(chan *amqpPublisher, size),
+		declared: map[string]bool{},
+	}
+}
+
+// get takes idle channel or opens new one when all are in use
+func (p *amqpChannelPool) get() (*amqpPublisher, error) {
+	for {
+		select {
+		case publisher := <-p.idle:
+			if publisher.IsClosed() {
+				continue
+			}
+			return publisher, nil
+		default:
+			channel, err := p.conn.Channel()
+			if err != nil {
+				return nil, err
+			}
+			return &amqpPublisher{Channel: channel}, nil
+		}
+	}
+}
+
+// put gives channel back to pool, closing it when pool is full
+func (p *amqpChannelPool) put(publisher *amqpPublisher) {
+	if publisher.IsClosed() {
+		return
+	}
+	select {
+	case p.idle <- publisher:
+	default:
+		publisher.Close()
+	}
+}
+
+// declare runs declaration identified by key once per connection.
+// Failed declaration is tried again next time.
+func (p *amqpChannelPool) declare(key string, declaration func() error) error {
+	p.declareLock.Lock()
+	defer p.declareLock.Unlock()
+	if p.declared[key] {
+		return nil
+	}
+	if err := declaration(); err != nil {
+		return err
+	}
+	p.declared[key] = true
+	return nil
+}
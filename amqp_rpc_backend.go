@@ -0,0 +1,287 @@
+// Copyright (c) 2019 Sick Yoon
+// This file is part of gocelery which is released under MIT license.
+// See file LICENSE for full license details.
+
+package gocelery
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// AMQPRPCBackend is CeleryBackend compatible with celery rpc:// result backend.
+// Client receives results of its tasks on one exclusive reply queue,
+// workers publish results to reply_to queue of task message with task id as correlation_id.
+// Results published while client is disconnected are lost with its exclusive queue.
+type AMQPRPCBackend struct {
+	*amqp.Channel
+	Connection *amqp.Connection
+	// ReplyQueue is name of exclusive queue results are delivered to
+	ReplyQueue string
+	// Host is dialed again when connection to AMQP server is lost
+	Host string
+	// ResultExpires is how long delivered result nobody waits for is kept, zero keeps it until read
+	ResultExpires time.Duration
+	channelLock   sync.RWMutex
+
+	resultLock sync.Mutex
+	results    map[string]rpcResult
+	waiters    map[string]map[chan struct{}]bool
+	swept      time.Time
+}
+
+// rpcResult is result delivered to reply queue and time it was delivered at
+type rpcResult struct {
+	message   *ResultMessage
+	delivered time.Time
+}
+
+// rpcSweepInterval limits how often delivered results are checked for expiry
+const rpcSweepInterval = time.Minute
+
+// NewAMQPRPCBackend creates new AMQPRPCBackend
+func NewAMQPRPCBackend(host string) (*AMQPRPCBackend, error) {
+	conn, channel, err := NewAMQPConnection(host)
+	if err != nil {
+		return nil, err
+	}
+	backend, err := newAMQPRPCBackend(conn, channel, host)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return backend, nil
+}
+
+// NewAMQPRPCBackendByConnAndChannel creates new AMQPRPCBackend by AMQP connection and channel.
+// Closed channel is opened again, but lost connection is not redialed without Host.
+func NewAMQPRPCBackendByConnAndChannel(conn *amqp.Connection, channel *amqp.Channel) (*AMQPRPCBackend, error) {
+	return newAMQPRPCBackend(conn, channel, "")
+}
+
+// newAMQPRPCBackend creates new AMQPRPCBackend and supervises its connection
+func newAMQPRPCBackend(conn *amqp.Connection, channel *amqp.Channel, host string) (*AMQPRPCBackend, error) {
+	backend := &AMQPRPCBackend{
+		Channel:       channel,
+		Connection:    conn,
+		ReplyQueue:    uuid.New().String(),
+		Host:          host,
+		ResultExpires: time.Hour,
+	}
+	if err := backend.setup(); err != nil {
+		return nil, err
+	}
+	go superviseConnection(conn, channel, backend.reconnect, backend.reopen)
+	return backend, nil
+}
+
+// setup declares reply queue and starts demultiplexing results delivered to it
+func (b *AMQPRPCBackend) setup() error {
+	channel, err := b.channel()
+	if err != nil {
+		return err
+	}
+	_, err = channel.QueueDeclare(
+		b.ReplyQueue, // name
+		false,        // durable
+		true,         // autoDelete
+		true,         // exclusive
+		false,        // noWait
+		nil,          // args
+	)
+	if err != nil {
+		return err
+	}
+	deliveries, err := channel.Consume(b.ReplyQueue, "", true, true, false, false, nil)
+	if err != nil {
+		return err
+	}
+	go b.demultiplex(deliveries)
+	return nil
+}
+
+// demultiplex stores results by task id and wakes their waiters until consumer is closed
+func (b *AMQPRPCBackend) demultiplex(deliveries <-chan amqp.Delivery) {
+	for delivery := range deliveries {
+		var resultMessage ResultMessage
+		if err := json.Unmarshal(delivery.Body, &resultMessage); err != nil {
+			log.Printf("failed to decode rpc result: %+v", err)
+			continue
+		}
+		taskID := delivery.CorrelationId
+		if taskID == "" {
+			taskID = resultMessage.ID
+		}
+		b.store(taskID, &resultMessage)
+	}
+}
+
+// store keeps delivered result, wakes its waiters and expires results nobody waits for
+func (b *AMQPRPCBackend) store(taskID string, result *ResultMessage) {
+	b.resultLock.Lock()
+	defer b.resultLock.Unlock()
+	now := time.Now()
+	if b.results == nil {
+		b.results = map[string]rpcResult{}
+	}
+	b.results[taskID] = rpcResult{message: result, delivered: now}
+	for waiter := range b.waiters[taskID] {
+		select {
+		case waiter <- struct{}{}:
+		default:
+		}
+	}
+	b.expire(now)
+}
+
+// expire drops results delivered longer than ResultExpires ago unless they are waited for
+func (b *AMQPRPCBackend) expire(now time.Time) {
+	if b.ResultExpires <= 0 {
+		return
+	}
+	interval := rpcSweepInterval
+	if b.ResultExpires < interval {
+		interval = b.ResultExpires
+	}
+	if now.Sub(b.swept) < interval {
+		return
+	}
+	b.swept = now
+	for taskID, result := range b.results {
+		if now.Sub(result.delivered) > b.ResultExpires && len(b.waiters[taskID]) == 0 {
+			delete(b.results, taskID)
+		}
+	}
+}
+
+// reconnect dials Host again and declares reply queue again
+func (b *AMQPRPCBackend) reconnect() (*amqp.Connection, *amqp.Channel, error) {
+	if b.Host == "" {
+		return nil, nil, errAMQPNoHost
+	}
+	conn, err := amqp.Dial(b.Host)
+	if err != nil {
+		return nil, nil, err
+	}
+	channel, err := b.reopen(conn)
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	return conn, channel, nil
+}
+
+// reopen opens new channel on conn and consumes reply queue again
+func (b *AMQPRPCBackend) reopen(conn *amqp.Connection) (*amqp.Channel, error) {
+	channel, err := conn.Channel()
+	if err != nil {
+		return nil, err
+	}
+	b.channelLock.Lock()
+	b.Connection, b.Channel = conn, channel
+	b.channelLock.Unlock()
+	if err := b.setup(); err != nil {
+		channel.Close()
+		return nil, err
+	}
+	return channel, nil
+}
+
+// channel returns current AMQP channel or ErrAMQPDisconnected while it is down
+func (b *AMQPRPCBackend) channel() (*amqp.Channel, error) {
+	b.channelLock.RLock()
+	channel := b.Channel
+	b.channelLock.RUnlock()
+	if channel == nil || channel.IsClosed() {
+		return nil, ErrAMQPDisconnected
+	}
+	return channel, nil
+}
+
+// ReplyTo returns name of queue results of tasks sent by client are delivered to
+func (b *AMQPRPCBackend) ReplyTo() string {
+	return b.ReplyQueue
+}
+
+// GetResult returns latest result of task delivered to reply queue
+func (b *AMQPRPCBackend) GetResult(taskID string) (*ResultMessage, error) {
+	b.resultLock.Lock()
+	defer b.resultLock.Unlock()
+	result, ok := b.results[taskID]
+	if !ok {
+		return nil, fmt.Errorf("result not available")
+	}
+	return result.message, nil
+}
+
+// WaitResult returns channel signalled when result of task is delivered to reply queue.
+// Returned cancel func must be called once waiting is over.
+func (b *AMQPRPCBackend) WaitResult(taskID string) (<-chan struct{}, func(), error) {
+	b.resultLock.Lock()
+	defer b.resultLock.Unlock()
+	if b.waiters == nil {
+		b.waiters = map[string]map[chan struct{}]bool{}
+	}
+	if b.waiters[taskID] == nil {
+		b.waiters[taskID] = map[chan struct{}]bool{}
+	}
+	notify := make(chan struct{}, 1)
+	b.waiters[taskID][notify] = true
+	if _, ok := b.results[taskID]; ok {
+		notify <- struct{}{}
+	}
+	cancel := func() {
+		b.resultLock.Lock()
+		defer b.resultLock.Unlock()
+		delete(b.waiters[taskID], notify)
+		if len(b.waiters[taskID]) == 0 {
+			delete(b.waiters, taskID)
+		}
+	}
+	return notify, cancel, nil
+}
+
+// Forget drops delivered result of task, AsyncResult calls it once it has read the result
+func (b *AMQPRPCBackend) Forget(taskID string) {
+	b.resultLock.Lock()
+	defer b.resultLock.Unlock()
+	delete(b.results, taskID)
+}
+
+// SetResult fails because rpc results can only be sent to reply queue of client
+func (b *AMQPRPCBackend) SetResult(taskID string, result *ResultMessage) error {
+	return fmt.Errorf("rpc backend requires reply_to of task %s", taskID)
+}
+
+// SetReplyResult publishes result to reply queue of client which sent the task
+func (b *AMQPRPCBackend) SetReplyResult(replyTo string, taskID string, result *ResultMessage) error {
+	result.ID = taskID
+	channel, err := b.channel()
+	if err != nil {
+		return err
+	}
+	resBytes, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	message := amqp.Publishing{
+		DeliveryMode:  amqp.Transient,
+		Timestamp:     time.Now(),
+		ContentType:   "application/json",
+		CorrelationId: taskID,
+		Body:          resBytes,
+	}
+	return channel.Publish(
+		"",
+		replyTo,
+		false,
+		false,
+		message,
+	)
+}
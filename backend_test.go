@@ -5,13 +5,16 @@
 package gocelery
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"math/rand"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
+	amqp "github.com/rabbitmq/amqp091-go"
 )
 
 // TestBackendRedisGetResult is Redis specific test to get result from backend
@@ -154,3 +157,175 @@ func TestBackendSetGetResult(t *testing.T) {
 		releaseResultMessage(resultMessage)
 	}
 }
+
+// stubReplyBackend records results sent to reply queues
+type stubReplyBackend struct {
+	stubBackend
+	replies map[string]string
+}
+
+func (b *stubReplyBackend) ReplyTo() string {
+	return "client-reply-queue"
+}
+
+func (b *stubReplyBackend) SetReplyResult(replyTo string, taskID string, result *ResultMessage) error {
+	b.replies[taskID] = replyTo
+	return b.SetResult(taskID, result)
+}
+
+// TestBackendRPCReplyTo tests that rpc results travel through reply queue of client
+func TestBackendRPCReplyTo(t *testing.T) {
+	broker := &stubBroker{}
+	backend := &stubReplyBackend{replies: map[string]string{}}
+	client, _ := NewCeleryClient(broker, backend, 1)
+	client.Register("add", add)
+	asyncResult, err := client.DelayV2("add", 1, 2)
+	if err != nil {
+		t.Fatalf("failed to send task: %v", err)
+	}
+	message := broker.sentV2[0]
+	if message.Properties.ReplyTo != "client-reply-queue" {
+		t.Fatalf("reply_to is not set to reply queue: %s", message.Properties.ReplyTo)
+	}
+	envelope := makeEnvelope(t, message)
+	client.worker.handleEnvelope(context.Background(), envelope)
+	if backend.replies[asyncResult.TaskID] != "client-reply-queue" {
+		t.Errorf("result was not sent to reply queue: %v", backend.replies)
+	}
+	if _, err := client.Delay("add", 1, 2); err != ErrReplyBackendV1 {
+		t.Errorf("expected protocol v1 task to be rejected, got %v", err)
+	}
+}
+
+// TestBackendRPCDemultiplex tests that delivered results are matched to tasks by correlation id
+func TestBackendRPCDemultiplex(t *testing.T) {
+	backend := &AMQPRPCBackend{}
+	deliveries := make(chan amqp.Delivery, 2)
+	deliveries <- amqp.Delivery{CorrelationId: "first", Body: []byte(`{"status": "SUCCESS", "result": 1}`)}
+	deliveries <- amqp.Delivery{Body: []byte(`{"task_id": "second", "status": "FAILURE"}`)}
+	close(deliveries)
+	backend.demultiplex(deliveries)
+	if res, err := backend.GetResult("first"); err != nil || res.Result != 1.0 {
+		t.Errorf("result is not matched by correlation id: %v %v", res, err)
+	}
+	if res, err := backend.GetResult("second"); err != nil || res.Status != "FAILURE" {
+		t.Errorf("result is not matched by task id: %v %v", res, err)
+	}
+	// result is forgotten by backend once it is read
+	asyncResult := &AsyncResult{TaskID: "first", backend: backend}
+	if val, err := asyncResult.AsyncGet(); err != nil || val != 1.0 {
+		t.Errorf("unexpected result %v: %v", val, err)
+	}
+	if _, err := backend.GetResult("first"); err == nil {
+		t.Error("read result is still kept by backend")
+	}
+	if val, err := asyncResult.AsyncGet(); err != nil || val != 1.0 {
+		t.Errorf("read result is not cached: %v %v", val, err)
+	}
+}
+
+// TestBackendRedisResubscribe is Redis specific test that waiter survives lost pub/sub connection
+func TestBackendRedisResubscribe(t *testing.T) {
+	backend := NewRedisBackend(redisPool)
+	taskID := uuid.New().String()
+	notify, cancel, err := backend.WaitResult(taskID)
+	if err != nil {
+		t.Fatalf("failed to wait for result: %v", err)
+	}
+	defer cancel()
+	time.Sleep(100 * time.Millisecond)
+	// server closes pub/sub connection in the middle of the wait
+	conn := redisPool.Get()
+	_, err = conn.Do("CLIENT", "KILL", "TYPE", "pubsub")
+	conn.Close()
+	if err != nil {
+		t.Fatalf("failed to kill pub/sub connection: %v", err)
+	}
+	// waiter is woken to poll once channels are subscribed again
+	select {
+	case <-notify:
+	case <-time.After(2 * time.Second):
+		t.Fatal("waiter was not woken after resubscribing")
+	}
+	time.Sleep(100 * time.Millisecond)
+	if err := backend.SetResult(taskID, &ResultMessage{ID: taskID, Status: "SUCCESS", Result: 3}); err != nil {
+		t.Fatalf("failed to set result: %v", err)
+	}
+	select {
+	case <-notify:
+	case <-time.After(time.Second):
+		t.Fatal("waiter was not woken by result published after resubscribing")
+	}
+}
+
+// TestBackendRPCWaitResult tests that delivered result wakes its waiter and unwaited results expire
+func TestBackendRPCWaitResult(t *testing.T) {
+	backend := &AMQPRPCBackend{ResultExpires: 10 * time.Millisecond}
+	deliveries := make(chan amqp.Delivery)
+	go backend.demultiplex(deliveries)
+	defer close(deliveries)
+
+	asyncResult := &AsyncResult{TaskID: "waited", backend: backend}
+	results := make(chan interface{})
+	go func() {
+		val, _ := asyncResult.Get(5 * time.Second)
+		results <- val
+	}()
+	// let waiter register before result is delivered
+	time.Sleep(50 * time.Millisecond)
+	deliveries <- amqp.Delivery{CorrelationId: "stale", Body: []byte(`{"status": "SUCCESS", "result": 1}`)}
+	deliveries <- amqp.Delivery{CorrelationId: "waited", Body: []byte(`{"status": "SUCCESS", "result": 2}`)}
+	select {
+	case val := <-results:
+		if val != 2.0 {
+			t.Errorf("unexpected result %v", val)
+		}
+	case <-time.After(500 * time.Millisecond):
+		t.Fatal("waiter was not woken by delivered result")
+	}
+
+	notify, cancel, _ := backend.WaitResult("kept")
+	defer cancel()
+	deliveries <- amqp.Delivery{CorrelationId: "kept", Body: []byte(`{"status": "SUCCESS", "result": 3}`)}
+	<-notify
+	time.Sleep(20 * time.Millisecond)
+	deliveries <- amqp.Delivery{CorrelationId: "fresh", Body: []byte(`{"status": "SUCCESS", "result": 4}`)}
+	// wait for fresh result to be stored and stale one to be swept
+	for i := 0; i < 100; i++ {
+		if _, err := backend.GetResult("fresh"); err == nil {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if _, err := backend.GetResult("stale"); err == nil {
+		t.Error("result nobody waits for did not expire")
+	}
+	if _, err := backend.GetResult("kept"); err != nil {
+		t.Errorf("waited result expired: %v", err)
+	}
+}
+
+// TestBackendRedisWaitResult is Redis specific test that stored result wakes its waiter
+func TestBackendRedisWaitResult(t *testing.T) {
+	backend := NewRedisBackend(redisPool)
+	taskID := uuid.New().String()
+	notify, cancel, err := backend.WaitResult(taskID)
+	if err != nil {
+		t.Fatalf("failed to wait for result: %v", err)
+	}
+	defer cancel()
+	// let subscription reach redis before result is published
+	time.Sleep(100 * time.Millisecond)
+	if err := backend.SetResult(taskID, &ResultMessage{ID: taskID, Status: "SUCCESS", Result: 3}); err != nil {
+		t.Fatalf("failed to set result: %v", err)
+	}
+	select {
+	case <-notify:
+	case <-time.After(time.Second):
+		t.Fatal("waiter was not woken by published result")
+	}
+	asyncResult := &AsyncResult{TaskID: taskID, backend: backend}
+	if val, err := asyncResult.Get(time.Second); err != nil || val != 3.0 {
+		t.Errorf("failed to get published result: %v %v", val, err)
+	}
+}
@@ -6,10 +6,18 @@ package gocelery
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"math/rand"
 	"reflect"
+	"strings"
+	"sync"
 	"testing"
 	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/google/uuid"
+	amqp "github.com/rabbitmq/amqp091-go"
 )
 
 func makeCeleryMessage() (*CeleryMessage, error) {
@@ -171,3 +179,522 @@ func TestBrokerSendGet(t *testing.T) {
 		releaseCeleryMessage(celeryMessage)
 	}
 }
+
+// TestBrokerRedisUnacked is Redis specific test for late acknowledgement
+// and restoration of messages whose visibility timeout has passed
+func TestBrokerRedisUnacked(t *testing.T) {
+	broker := NewRedisBroker(redisPool, map[string]string{})
+	broker.AcksLate = true
+	broker.VisibilityTimeout = 0
+	celeryMessage, err := makeCeleryMessage()
+	if err != nil {
+		t.Fatalf("failed to construct celery message: %v", err)
+	}
+	defer releaseCeleryMessage(celeryMessage)
+	if err := broker.SendCeleryMessage(celeryMessage); err != nil {
+		t.Fatalf("failed to send celery message to broker: %v", err)
+	}
+	envelope, err := broker.GetCeleryEnvelope()
+	if err != nil || envelope == nil || envelope.Delivery == nil {
+		t.Fatalf("failed to get celery message from broker: %v", err)
+	}
+	conn := broker.Get()
+	defer conn.Close()
+	exists, err := redis.Bool(conn.Do("HEXISTS", broker.UnackedKey, celeryMessage.Properties.DeliveryTag))
+	if err != nil || !exists {
+		t.Fatalf("fetched message is not kept in unacked store: %v", err)
+	}
+	restored, err := broker.RestoreUnacked()
+	if err != nil || restored != 1 {
+		t.Fatalf("expected 1 restored message, got %d: %v", restored, err)
+	}
+	envelope, err = broker.GetCeleryEnvelope()
+	if err != nil || envelope == nil {
+		t.Fatalf("failed to get restored message from broker: %v", err)
+	}
+	if _, err := envelope.GetTaskMessage(); err != nil {
+		t.Errorf("failed to decode restored message: %v", err)
+	}
+	if err := envelope.Delivery.Ack(); err != nil {
+		t.Fatalf("failed to acknowledge message: %v", err)
+	}
+	exists, err = redis.Bool(conn.Do("HEXISTS", broker.UnackedKey, celeryMessage.Properties.DeliveryTag))
+	if err != nil || exists {
+		t.Errorf("acknowledged message is still in unacked store: %v", err)
+	}
+}
+
+// TestBrokerRedisUnackedInvalid is Redis specific test that messages fetched with AcksLate are never dropped
+func TestBrokerRedisUnackedInvalid(t *testing.T) {
+	broker := NewRedisBroker(redisPool, map[string]string{})
+	broker.AcksLate = true
+	broker.QueueName = uuid.New().String()
+	broker.DeadLetterKey = broker.QueueName + "-dead"
+	conn := broker.Get()
+	defer conn.Close()
+	defer conn.Do("DEL", broker.DeadLetterKey)
+	if _, err := conn.Do("LPUSH", broker.QueueName, "not json", `{"properties": {}}`); err != nil {
+		t.Fatalf("failed to push messages: %v", err)
+	}
+	if _, err := broker.GetCeleryEnvelope(); err == nil {
+		t.Error("expected error for message which is not json")
+	}
+	dead, err := redis.Strings(conn.Do("LRANGE", broker.DeadLetterKey, 0, -1))
+	if err != nil || len(dead) != 1 || dead[0] != "not json" {
+		t.Errorf("invalid message was not moved to dead letter list: %v %v", dead, err)
+	}
+	envelope, err := broker.GetCeleryEnvelope()
+	if err != nil || envelope.Delivery == nil {
+		t.Fatalf("failed to get message without delivery tag: %v", err)
+	}
+	tag := envelope.Delivery.(*redisDelivery).tag
+	exists, err := redis.Bool(conn.Do("HEXISTS", broker.UnackedKey, tag))
+	if err != nil || !exists {
+		t.Errorf("message without delivery tag is not kept in unacked store: %v", err)
+	}
+	if err := envelope.Delivery.Ack(); err != nil {
+		t.Errorf("failed to acknowledge message: %v", err)
+	}
+}
+
+// TestBrokerAMQPDisconnected tests that sends fail with clear error while connection is down
+func TestBrokerAMQPDisconnected(t *testing.T) {
+	broker := &AMQPCeleryBroker{
+		Exchange: NewAMQPExchange("default"),
+		Queue:    NewAMQPQueue("celery"),
+	}
+	celeryMessage, err := makeCeleryMessage()
+	if err != nil {
+		t.Fatalf("failed to construct celery message: %v", err)
+	}
+	defer releaseCeleryMessage(celeryMessage)
+	if err := broker.SendCeleryMessage(celeryMessage); !errors.Is(err, ErrAMQPDisconnected) {
+		t.Errorf("expected ErrAMQPDisconnected, got %v", err)
+	}
+	if _, err := broker.GetTaskMessage(); err == nil {
+		t.Error("expected error getting message from disconnected broker")
+	}
+	if _, _, err := broker.reconnect(); !errors.Is(err, errAMQPNoHost) {
+		t.Errorf("expected reconnect without host to fail, got %v", err)
+	}
+}
+
+// TestBrokerAMQPWireFormatV2 tests kombu compatible mapping of v2 message to AMQP message
+func TestBrokerAMQPWireFormatV2(t *testing.T) {
+	args := []interface{}{1.0, "two"}
+	kwargs := map[string]interface{}{"three": 3.0}
+	taskMessage := getTaskMessageV2WithKwargs(args, kwargs)
+	defer releaseTaskMessageV2(taskMessage)
+	encoded, err := taskMessage.Encode()
+	if err != nil {
+		t.Fatalf("failed to encode task message: %v", err)
+	}
+	headers := buildCeleryHeadersV2("tasks.add", args, kwargs)
+	defer releaseCeleryMessageHeadersV2(headers)
+	celeryMessage := getCeleryMessageV2(encoded, *headers)
+	defer releaseCeleryMessageV2(celeryMessage)
+	celeryMessage.Properties.Priority = 5
+
+	publishing, err := newAMQPPublishingV2(celeryMessage)
+	if err != nil {
+		t.Fatalf("failed to map message to AMQP: %v", err)
+	}
+	if err := publishing.Headers.Validate(); err != nil {
+		t.Fatalf("AMQP headers are invalid: %v", err)
+	}
+	if publishing.Headers["task"] != "tasks.add" || publishing.Headers["id"] != headers.ID {
+		t.Errorf("task headers are not mapped to AMQP headers: %v", publishing.Headers)
+	}
+	var body []interface{}
+	if err := json.Unmarshal(publishing.Body, &body); err != nil || len(body) != 3 {
+		t.Fatalf("AMQP body is not raw [args, kwargs, embed] json: %s", publishing.Body)
+	}
+	if publishing.CorrelationId != headers.ID || publishing.Priority != 5 {
+		t.Errorf("properties are not mapped to AMQP publishing: %+v", publishing)
+	}
+
+	received, err := newCeleryMessageV2FromDelivery(amqp.Delivery{
+		Headers:         publishing.Headers,
+		ContentType:     publishing.ContentType,
+		ContentEncoding: publishing.ContentEncoding,
+		CorrelationId:   publishing.CorrelationId,
+		Priority:        publishing.Priority,
+		DeliveryTag:     7,
+		RoutingKey:      "celery",
+		Body:            publishing.Body,
+	})
+	if err != nil {
+		t.Fatalf("failed to map AMQP delivery to message: %v", err)
+	}
+	if received.Headers.Task != headers.Task || received.Headers.ID != headers.ID || received.Headers.Argsrepr != headers.Argsrepr {
+		t.Errorf("received headers %+v differ from sent headers %+v", received.Headers, headers)
+	}
+	receivedTask := received.GetTaskMessageV2()
+	if receivedTask == nil {
+		t.Fatal("failed to decode received task message")
+	}
+	defer releaseTaskMessageV2(receivedTask)
+	if !reflect.DeepEqual(receivedTask.Args, args) || !reflect.DeepEqual(receivedTask.Kwargs, kwargs) {
+		t.Errorf("received args %v kwargs %v differ from sent", receivedTask.Args, receivedTask.Kwargs)
+	}
+}
+
+// TestBrokerRedisQueueOrder tests priority and round robin ordering of consumed queues
+func TestBrokerRedisQueueOrder(t *testing.T) {
+	broker := NewRedisBroker(redisPool, map[string]string{})
+	if queues := broker.consumedQueues(); !reflect.DeepEqual(queues, []string{"celery"}) {
+		t.Errorf("expected default queue to be consumed, got %v", queues)
+	}
+	broker.Queues = []string{"high", "default", "low"}
+	for i := 0; i < 3; i++ {
+		if queues := broker.consumedQueues(); !reflect.DeepEqual(queues, broker.Queues) {
+			t.Errorf("expected queues in priority order, got %v", queues)
+		}
+	}
+	broker.RoundRobin = true
+	first := map[string]bool{}
+	for i := 0; i < 3; i++ {
+		queues := broker.consumedQueues()
+		if len(queues) != 3 {
+			t.Fatalf("expected 3 queues, got %v", queues)
+		}
+		first[queues[0]] = true
+	}
+	if len(first) != 3 {
+		t.Errorf("expected every queue to be tried first in turn, got %v", first)
+	}
+}
+
+// TestBrokerRedisPriorityQueue tests that priorities map to celery priority step lists
+func TestBrokerRedisPriorityQueue(t *testing.T) {
+	broker := NewRedisBroker(redisPool, map[string]string{})
+	cases := map[int]string{
+		-1: "celery",
+		0:  "celery",
+		2:  "celery",
+		3:  "celery\x06\x163",
+		5:  "celery\x06\x163",
+		9:  "celery\x06\x169",
+		12: "celery\x06\x169",
+	}
+	for priority, expected := range cases {
+		if queueName := broker.priorityQueue("celery", priority); queueName != expected {
+			t.Errorf("priority %d: expected list %q, got %q", priority, expected, queueName)
+		}
+	}
+	v1 := []byte(`{"properties": {"delivery_info": {"priority": 6}}}`)
+	v2 := []byte(`{"properties": {"priority": 3, "delivery_info": {}}}`)
+	if messagePriority(v1) != 6 || messagePriority(v2) != 3 {
+		t.Errorf("failed to read message priority: %d %d", messagePriority(v1), messagePriority(v2))
+	}
+}
+
+// TestBrokerRedisPriority tests that messages of higher priority are consumed first
+func TestBrokerRedisPriority(t *testing.T) {
+	broker := NewRedisBroker(redisPool, map[string]string{})
+	broker.QueueName = "gocelery-priority-test"
+	conn := redisPool.Get()
+	defer conn.Close()
+	for _, step := range broker.PrioritySteps {
+		conn.Do("DEL", broker.priorityQueue(broker.QueueName, step))
+	}
+	for _, priority := range []int{9, 0, 6} {
+		message := getCeleryMessageV2("", CeleryHeadersV2{ID: fmt.Sprint(priority), Task: "add"})
+		message.Properties.DeliveryInfo.RoutingKey = broker.QueueName
+		message.Properties.Priority = priority
+		err := broker.SendCeleryMessageV2(message)
+		releaseCeleryMessageV2(message)
+		if err != nil {
+			t.Fatalf("failed to send message: %v", err)
+		}
+	}
+	for _, expected := range []string{"0", "6", "9"} {
+		envelope, err := broker.GetCeleryEnvelope()
+		if err != nil {
+			t.Fatalf("failed to get message: %v", err)
+		}
+		if id := envelope.Headers["id"]; id != expected {
+			t.Errorf("expected message of priority %s, got %v", expected, id)
+		}
+	}
+}
+
+// TestBrokerAMQPQueueConfig tests that queue settings and bindings are resolved by queue name
+func TestBrokerAMQPQueueConfig(t *testing.T) {
+	broker := &AMQPCeleryBroker{
+		Exchange: NewAMQPExchange("default"),
+		Queue:    NewAMQPPriorityQueue("celery", 10),
+		QueueConfigs: map[string]*AMQPQueue{
+			"events": {
+				Name:     "events",
+				Durable:  true,
+				Bindings: []*AMQPBinding{{Exchange: "events", RoutingKey: "events.#"}},
+			},
+			"audit": {
+				Name:     "audit",
+				Durable:  true,
+				Bindings: []*AMQPBinding{{Exchange: "events", RoutingKey: "audit.*"}, {Exchange: "broadcast", RoutingKey: "#"}},
+			},
+		},
+		ExchangeConfigs: map[string]*AMQPExchange{
+			"events":    {Name: "events", Type: "topic", Durable: true},
+			"broadcast": {Name: "broadcast", Type: "fanout", Durable: true},
+		},
+	}
+	broker.Queues = []*AMQPQueue{broker.Queue, NewAMQPQuorumQueue("reliable")}
+	if queue := broker.queueConfig("celery"); queue.Args["x-max-priority"] != int32(10) {
+		t.Errorf("priority queue settings are not used: %+v", queue)
+	}
+	if queue := broker.queueConfig("reliable"); queue.Args["x-queue-type"] != "quorum" || !queue.Durable {
+		t.Errorf("quorum queue settings are not used: %+v", queue)
+	}
+	if queue := broker.queueConfig("other"); queue.Args != nil || !queue.Durable {
+		t.Errorf("unknown queue should use default settings: %+v", queue)
+	}
+	events := broker.queueConfig("events")
+	if exchangeName, routingKey := broker.bindingRoute(events, events.Bindings[0]); exchangeName != "events" || routingKey != "events.#" {
+		t.Errorf("unexpected binding route %s %s", exchangeName, routingKey)
+	}
+	if exchangeName, routingKey := broker.bindingRoute(events, &AMQPBinding{}); exchangeName != "default" || routingKey != "events" {
+		t.Errorf("binding defaults are not applied: %s %s", exchangeName, routingKey)
+	}
+	if exchange := broker.exchangeConfig("events"); exchange.Type != "topic" || exchange.AutoDelete {
+		t.Errorf("exchange settings are not used: %+v", exchange)
+	}
+	if exchange := broker.exchangeConfig("other"); exchange.Type != "direct" {
+		t.Errorf("unknown exchange should use default settings: %+v", exchange)
+	}
+	// wildcard patterns are never used as routing keys
+	if exchangeName, routingKey := broker.publishRoute(events); exchangeName != "" || routingKey != "events" {
+		t.Errorf("unexpected publish route %q %q", exchangeName, routingKey)
+	}
+	if exchangeName, routingKey := broker.publishRoute(broker.queueConfig("audit")); exchangeName != "broadcast" || routingKey != "audit" {
+		t.Errorf("unexpected fanout publish route %q %q", exchangeName, routingKey)
+	}
+	if exchangeName, routingKey := broker.publishRoute(broker.queueConfig("other")); exchangeName != "default" || routingKey != "other" {
+		t.Errorf("unexpected default publish route %q %q", exchangeName, routingKey)
+	}
+	if err := broker.Queue.Args.Validate(); err != nil {
+		t.Errorf("queue arguments are invalid: %v", err)
+	}
+}
+
+// TestBrokerRedisDelayed tests that messages with future ETA wait in sorted set until they are due
+func TestBrokerRedisDelayed(t *testing.T) {
+	broker := NewRedisBroker(redisPool, map[string]string{})
+	broker.QueueName = "gocelery-delayed-test"
+	broker.DelayedDelivery = true
+	conn := redisPool.Get()
+	defer conn.Close()
+	conn.Do("DEL", broker.QueueName, broker.DelayedKeyPrefix+broker.QueueName)
+
+	message := getCeleryMessageV2("", CeleryHeadersV2{ID: "delayed", Task: "add"})
+	defer releaseCeleryMessageV2(message)
+	message.Properties.DeliveryInfo.RoutingKey = broker.QueueName
+	message.Headers.Eta = formatETA(time.Now().Add(time.Second))
+	if err := broker.SendCeleryMessageV2(message); err != nil {
+		t.Fatalf("failed to send delayed message: %v", err)
+	}
+	if moved, err := broker.MoveDelayed(); err != nil || moved != 0 {
+		t.Fatalf("message was moved before its eta: %d %v", moved, err)
+	}
+	if length, _ := redis.Int(conn.Do("LLEN", broker.QueueName)); length != 0 {
+		t.Fatalf("delayed message was pushed to queue")
+	}
+	time.Sleep(1100 * time.Millisecond)
+	if moved, err := broker.MoveDelayed(); err != nil || moved != 1 {
+		t.Fatalf("due message was not moved: %d %v", moved, err)
+	}
+	envelope, err := broker.GetCeleryEnvelope()
+	if err != nil || envelope.Headers["id"] != "delayed" {
+		t.Errorf("failed to get moved message: %v", err)
+	}
+}
+
+// TestBrokerAMQPDelayedRoutingKey tests that countdown is encoded like celery native delayed delivery
+func TestBrokerAMQPDelayedRoutingKey(t *testing.T) {
+	routingKey := amqpDelayedRoutingKey(5, "celery")
+	expected := strings.Repeat("0.", 25) + "1.0.1.celery"
+	if routingKey != expected {
+		t.Errorf("expected routing key %s, got %s", expected, routingKey)
+	}
+	if routingKey := amqpDelayedRoutingKey(1<<40, "celery"); routingKey != strings.Repeat("1.", 28)+"celery" {
+		t.Errorf("countdown over maximum delay is not capped: %s", routingKey)
+	}
+}
+
+// TestBrokerAMQPPublishConfirm is AMQP specific test that unroutable messages fail to send in confirm mode
+func TestBrokerAMQPPublishConfirm(t *testing.T) {
+	broker, err := NewAMQPCeleryBroker("amqp://")
+	if err != nil {
+		t.Skipf("AMQP server is not available: %v", err)
+	}
+	defer broker.Connection.Close()
+	broker.PublishConfirm = true
+	pool, err := broker.publisherPool()
+	if err != nil {
+		t.Fatalf("failed to get channel pool: %v", err)
+	}
+	publisher, err := pool.get()
+	if err != nil {
+		t.Fatalf("failed to get channel: %v", err)
+	}
+	defer pool.put(publisher)
+	if _, err := publisher.QueueDeclare("gocelery-confirm-test", false, true, false, false, nil); err != nil {
+		t.Fatalf("failed to declare queue: %v", err)
+	}
+	err = broker.publish(publisher, "", "gocelery-confirm-test", amqp.Publishing{Body: []byte("{}")})
+	if err != nil {
+		t.Errorf("routable message failed to send: %v", err)
+	}
+	err = broker.publish(publisher, "", "gocelery-missing-queue", amqp.Publishing{Body: []byte("{}")})
+	if !errors.Is(err, ErrAMQPPublishReturned) {
+		t.Errorf("expected unroutable message to be returned, got %v", err)
+	}
+	err = broker.publish(publisher, "", "gocelery-confirm-test", amqp.Publishing{Body: []byte("{}")})
+	if err != nil {
+		t.Errorf("routable message after returned one failed to send: %v", err)
+	}
+}
+
+// TestBrokerAMQPConfirmTimeout tests that returns of other messages are dropped while waiting for confirm
+func TestBrokerAMQPConfirmTimeout(t *testing.T) {
+	publisher := &amqpPublisher{returns: make(chan amqp.Return, 1)}
+	publisher.returns <- amqp.Return{MessageId: "late"}
+	// confirmation which never completes
+	_, err := publisher.waitConfirm(&amqp.DeferredConfirmation{}, "current", 20*time.Millisecond)
+	if !errors.Is(err, ErrAMQPConfirmTimeout) {
+		t.Errorf("expected confirm timeout, got %v", err)
+	}
+	if len(publisher.returns) != 0 {
+		t.Error("expected return of other message to be dropped")
+	}
+}
+
+// TestBrokerAMQPChannelReopen is AMQP specific test that channel closed by exception is opened again
+func TestBrokerAMQPChannelReopen(t *testing.T) {
+	broker, err := NewAMQPCeleryBroker("amqp://")
+	if err != nil {
+		t.Skipf("AMQP server is not available: %v", err)
+	}
+	defer broker.Connection.Close()
+	closed, err := broker.channel()
+	if err != nil {
+		t.Fatalf("failed to get channel: %v", err)
+	}
+	// declaring missing queue passively closes channel with NOT_FOUND
+	if _, err := closed.QueueDeclarePassive("gocelery-missing-queue", false, true, false, false, nil); err == nil {
+		t.Fatal("expected passive declaration of missing queue to fail")
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		channel, err := broker.channel()
+		if err == nil && channel != closed {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("channel was not reopened: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	celeryMessage, err := makeCeleryMessage()
+	if err != nil {
+		t.Fatalf("failed to construct celery message: %v", err)
+	}
+	defer releaseCeleryMessage(celeryMessage)
+	if err := broker.SendCeleryMessage(celeryMessage); err != nil {
+		t.Fatalf("failed to send message after channel was reopened: %v", err)
+	}
+	if _, err := broker.GetTaskMessage(); err != nil {
+		t.Errorf("failed to consume message after channel was reopened: %v", err)
+	}
+}
+
+// TestBrokerAMQPAddConsumerConcurrent is AMQP specific test that concurrent calls start one consumer per queue
+func TestBrokerAMQPAddConsumerConcurrent(t *testing.T) {
+	broker, err := NewAMQPCeleryBroker("amqp://")
+	if err != nil {
+		t.Skipf("AMQP server is not available: %v", err)
+	}
+	defer broker.Connection.Close()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := broker.AddConsumer("gocelery-add-consumer-test"); err != nil {
+				t.Errorf("failed to add consumer: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if queues := broker.ConsumingQueues(); len(queues) != 2 {
+		t.Errorf("expected queue to be consumed once, got %v", queues)
+	}
+	if err := broker.CancelConsumer("gocelery-add-consumer-test"); err != nil {
+		t.Errorf("failed to cancel consumer: %v", err)
+	}
+}
+
+// TestBrokerAMQPStopConsuming is AMQP specific test that prefetched messages are requeued when consuming stops
+func TestBrokerAMQPStopConsuming(t *testing.T) {
+	broker, err := NewAMQPCeleryBroker("amqp://")
+	if err != nil {
+		t.Skipf("AMQP server is not available: %v", err)
+	}
+	defer broker.Connection.Close()
+	for i := 0; i < 2; i++ {
+		celeryMessage, err := makeCeleryMessage()
+		if err != nil {
+			t.Fatalf("failed to construct celery message: %v", err)
+		}
+		err = broker.SendCeleryMessage(celeryMessage)
+		releaseCeleryMessage(celeryMessage)
+		if err != nil {
+			t.Fatalf("failed to send message: %v", err)
+		}
+	}
+	// let consumer prefetch messages nobody takes
+	time.Sleep(100 * time.Millisecond)
+	if err := broker.StopConsuming(); err != nil {
+		t.Fatalf("failed to stop consuming: %v", err)
+	}
+	channel, err := broker.Connection.Channel()
+	if err != nil {
+		t.Fatalf("failed to open channel: %v", err)
+	}
+	defer channel.Close()
+	queue, err := channel.QueueDeclarePassive(broker.Queue.Name, true, false, false, false, nil)
+	if err != nil {
+		t.Fatalf("failed to inspect queue: %v", err)
+	}
+	if queue.Consumers != 0 || queue.Messages < 2 {
+		t.Errorf("expected prefetched messages to be requeued without consumers, got %d messages and %d consumers",
+			queue.Messages, queue.Consumers)
+	}
+}
+
+// TestBrokerAMQPDeclareCache tests that declarations are made once per connection
+func TestBrokerAMQPDeclareCache(t *testing.T) {
+	pool := newAMQPChannelPool(nil, 2)
+	calls := 0
+	failing := func() error {
+		calls++
+		return errors.New("declare failed")
+	}
+	declaring := func() error {
+		calls++
+		return nil
+	}
+	if err := pool.declare("queue:celery", failing); err == nil {
+		t.Fatal("expected declaration error")
+	}
+	for i := 0; i < 3; i++ {
+		if err := pool.declare("queue:celery", declaring); err != nil {
+			t.Fatalf("failed to declare: %v", err)
+		}
+	}
+	if calls != 2 {
+		t.Errorf("expected failed declaration to be retried once and then cached, got %d calls", calls)
+	}
+}
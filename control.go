@@ -0,0 +1,174 @@
+// Copyright (c) 2019 Sick Yoon
+// This file is part of gocelery which is released under MIT license.
+// See file LICENSE for full license details.
+
+package gocelery
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+)
+
+// ControlMessage is celery remote control command broadcast through pidbox
+// https://docs.celeryq.dev/en/stable/userguide/workers.html#remote-control
+type ControlMessage struct {
+	Method      string                 `json:"method"`
+	Arguments   map[string]interface{} `json:"arguments"`
+	Destination []string               `json:"destination"`
+	Ticket      string                 `json:"ticket"`
+	// ReplyTo is where caller waits for replies, nil when no reply is expected
+	ReplyTo *ControlReplyTo `json:"reply_to"`
+}
+
+// ControlReplyTo is exchange and routing key replies to control command are published to
+type ControlReplyTo struct {
+	Exchange   string `json:"exchange"`
+	RoutingKey string `json:"routing_key"`
+}
+
+// ConsumerController is interface for broker able to change consumed queues at runtime
+type ConsumerController interface {
+	AddConsumer(queue string) error
+	CancelConsumer(queue string) error
+	ConsumingQueues() []string
+}
+
+// CeleryControlBroker is interface for broker delivering remote control commands to worker.
+// Returned channel is closed when ctx is done or consuming fails.
+type CeleryControlBroker interface {
+	ConsumeControl(ctx context.Context, hostname string) (<-chan *ControlMessage, error)
+	// ReplyControl publishes {hostname: reply} to ReplyTo of message with its ticket
+	ReplyControl(message *ControlMessage, hostname string, reply map[string]interface{}) error
+}
+
+const (
+	controlRetryMinBackoff = 100 * time.Millisecond
+	controlRetryMaxBackoff = 30 * time.Second
+)
+
+// decodeControlMessage decodes control command from kombu message body
+func decodeControlMessage(body []byte, bodyEncoding string) (*ControlMessage, error) {
+	if bodyEncoding == "base64" {
+		decoded, err := base64.StdEncoding.DecodeString(string(body))
+		if err != nil {
+			return nil, err
+		}
+		body = decoded
+	}
+	var message ControlMessage
+	if err := json.Unmarshal(body, &message); err != nil {
+		return nil, err
+	}
+	return &message, nil
+}
+
+// AddConsumer starts consuming queue without restarting worker
+func (w *CeleryWorker) AddConsumer(queue string) error {
+	controller, ok := w.broker.(ConsumerController)
+	if !ok {
+		return fmt.Errorf("broker %T does not support adding consumers", w.broker)
+	}
+	return controller.AddConsumer(queue)
+}
+
+// CancelConsumer stops consuming queue without restarting worker
+func (w *CeleryWorker) CancelConsumer(queue string) error {
+	controller, ok := w.broker.(ConsumerController)
+	if !ok {
+		return fmt.Errorf("broker %T does not support cancelling consumers", w.broker)
+	}
+	return controller.CancelConsumer(queue)
+}
+
+// HandleControl runs remote control command addressed to this worker.
+// Commands addressed to other workers are ignored.
+func (w *CeleryWorker) HandleControl(message *ControlMessage) (map[string]interface{}, error) {
+	if len(message.Destination) > 0 {
+		addressed := false
+		for _, destination := range message.Destination {
+			addressed = addressed || destination == w.hostname
+		}
+		if !addressed {
+			return nil, nil
+		}
+	}
+	queue, _ := message.Arguments["queue"].(string)
+	switch message.Method {
+	case "add_consumer":
+		if queue == "" {
+			return nil, fmt.Errorf("add_consumer requires queue argument")
+		}
+		if err := w.AddConsumer(queue); err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"ok": fmt.Sprintf("add consumer %s", queue)}, nil
+	case "cancel_consumer":
+		if queue == "" {
+			return nil, fmt.Errorf("cancel_consumer requires queue argument")
+		}
+		if err := w.CancelConsumer(queue); err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"ok": fmt.Sprintf("no longer consuming from %s", queue)}, nil
+	default:
+		return nil, fmt.Errorf("unsupported control command %s", message.Method)
+	}
+}
+
+// consumeControl runs remote control commands until ctx is done.
+// Consuming is started again with backoff when it fails or stops.
+func (w *CeleryWorker) consumeControl(ctx context.Context, controlBroker CeleryControlBroker) {
+	backoff := controlRetryMinBackoff
+	for {
+		messages, err := controlBroker.ConsumeControl(ctx, w.hostname)
+		if err == nil {
+			backoff = controlRetryMinBackoff
+			for message := range messages {
+				w.runControl(controlBroker, message)
+			}
+		} else {
+			log.Printf("failed to consume control commands, retrying in %v: %+v", backoff, err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		if err != nil {
+			if backoff *= 2; backoff > controlRetryMaxBackoff {
+				backoff = controlRetryMaxBackoff
+			}
+		}
+	}
+}
+
+// runControl runs control command and replies to caller waiting for it
+func (w *CeleryWorker) runControl(controlBroker CeleryControlBroker, message *ControlMessage) {
+	reply, err := w.HandleControl(message)
+	if err != nil {
+		log.Printf("failed to run control command %s: %+v", message.Method, err)
+		reply = map[string]interface{}{"error": err.Error()}
+	}
+	if reply == nil {
+		// command is addressed to other workers
+		return
+	}
+	if err == nil {
+		log.Printf("control command %s: %v", message.Method, reply["ok"])
+	}
+	if message.ReplyTo == nil {
+		return
+	}
+	if err := controlBroker.ReplyControl(message, w.hostname, reply); err != nil {
+		log.Printf("failed to reply to control command %s: %+v", message.Method, err)
+	}
+}
+
+// controlReplyBody encodes reply of worker hostname the way celery mailbox does
+func controlReplyBody(hostname string, reply map[string]interface{}) ([]byte, error) {
+	return json.Marshal(map[string]interface{}{hostname: reply})
+}
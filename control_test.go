@@ -0,0 +1,139 @@
+// Copyright (c) 2019 Sick Yoon
+// This file is part of gocelery which is released under MIT license.
+// See file LICENSE for full license details.
+
+package gocelery
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// TestHandleControlConsumers tests that add_consumer and cancel_consumer change consumed queues
+func TestHandleControlConsumers(t *testing.T) {
+	broker := NewRedisBroker(nil, nil)
+	celeryWorker := NewCeleryWorker(broker, &stubBackend{}, 1)
+	celeryWorker.SetHostname("celery@test")
+
+	reply, err := celeryWorker.HandleControl(&ControlMessage{
+		Method:    "add_consumer",
+		Arguments: map[string]interface{}{"queue": "images"},
+	})
+	if err != nil || reply == nil {
+		t.Fatalf("failed to add consumer: %v", err)
+	}
+	if queues := broker.ConsumingQueues(); !reflect.DeepEqual(queues, []string{"celery", "images"}) {
+		t.Errorf("unexpected queues after add_consumer: %v", queues)
+	}
+
+	_, err = celeryWorker.HandleControl(&ControlMessage{
+		Method:      "cancel_consumer",
+		Arguments:   map[string]interface{}{"queue": "celery"},
+		Destination: []string{"celery@test"},
+	})
+	if err != nil {
+		t.Fatalf("failed to cancel consumer: %v", err)
+	}
+	if queues := broker.ConsumingQueues(); !reflect.DeepEqual(queues, []string{"images"}) {
+		t.Errorf("unexpected queues after cancel_consumer: %v", queues)
+	}
+}
+
+// TestHandleControlDestination tests that commands addressed to other workers are ignored
+func TestHandleControlDestination(t *testing.T) {
+	broker := NewRedisBroker(nil, nil)
+	celeryWorker := NewCeleryWorker(broker, &stubBackend{}, 1)
+	celeryWorker.SetHostname("celery@test")
+	reply, err := celeryWorker.HandleControl(&ControlMessage{
+		Method:      "cancel_consumer",
+		Arguments:   map[string]interface{}{"queue": "celery"},
+		Destination: []string{"celery@other"},
+	})
+	if err != nil || reply != nil {
+		t.Errorf("command for other worker was handled: %v %v", reply, err)
+	}
+	if queues := broker.ConsumingQueues(); !reflect.DeepEqual(queues, []string{"celery"}) {
+		t.Errorf("queues changed by command for other worker: %v", queues)
+	}
+	if _, err := celeryWorker.HandleControl(&ControlMessage{Method: "add_consumer"}); err == nil {
+		t.Error("add_consumer without queue should fail")
+	}
+}
+
+// stubControlBroker fails to consume control commands once and records replies
+type stubControlBroker struct {
+	stubBroker
+	calls    int
+	messages chan *ControlMessage
+	replies  chan []byte
+}
+
+func (b *stubControlBroker) ConsumeControl(ctx context.Context, hostname string) (<-chan *ControlMessage, error) {
+	b.Lock()
+	defer b.Unlock()
+	if b.calls++; b.calls == 1 {
+		return nil, errors.New("broker is not up yet")
+	}
+	messages := make(chan *ControlMessage)
+	go func() {
+		defer close(messages)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case message := <-b.messages:
+				messages <- message
+			}
+		}
+	}()
+	return messages, nil
+}
+
+func (b *stubControlBroker) ReplyControl(message *ControlMessage, hostname string, reply map[string]interface{}) error {
+	body, err := controlReplyBody(hostname, reply)
+	if err != nil {
+		return err
+	}
+	b.replies <- body
+	return nil
+}
+
+// TestConsumeControlReply tests that consuming is retried and commands are answered
+func TestConsumeControlReply(t *testing.T) {
+	broker := &stubControlBroker{messages: make(chan *ControlMessage, 1), replies: make(chan []byte, 1)}
+	celeryWorker := NewCeleryWorker(broker, &stubBackend{}, 1)
+	celeryWorker.SetHostname("celery@test")
+
+	message, err := decodeControlMessage([]byte(`{"method": "cancel_consumer", "arguments": {"queue": "images"},
+		"destination": null, "ticket": "ticket-1",
+		"reply_to": {"exchange": "reply.celery.pidbox", "routing_key": "caller"}}`), "")
+	if err != nil {
+		t.Fatalf("failed to decode control message: %v", err)
+	}
+	if message.ReplyTo == nil || message.ReplyTo.Exchange != "reply.celery.pidbox" || message.ReplyTo.RoutingKey != "caller" {
+		t.Fatalf("reply_to is not decoded: %+v", message.ReplyTo)
+	}
+	broker.messages <- message
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		celeryWorker.consumeControl(ctx, broker)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+	select {
+	case reply := <-broker.replies:
+		if string(reply) != `{"celery@test":{"error":"broker *gocelery.stubControlBroker does not support cancelling consumers"}}` {
+			t.Errorf("unexpected reply %s", reply)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("control command was not answered after consuming failed once")
+	}
+}
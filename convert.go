@@ -5,15 +5,29 @@
 package gocelery
 
 import (
+	"encoding/json"
+	"errors"
 	"reflect"
 )
 
+var (
+	errorType         = reflect.TypeOf((*error)(nil)).Elem()
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
 // GetRealValue returns real value of reflect.Value
 // Required for JSON Marshalling
 func GetRealValue(val *reflect.Value) interface{} {
-	if val == nil {
+	if val == nil || !val.IsValid() {
 		return nil
 	}
+	// types marshalling themselves, e.g. time.Time, are kept as they are
+	if val.Type().Implements(jsonMarshalerType) && val.CanInterface() {
+		if (val.Kind() == reflect.Ptr || val.Kind() == reflect.Interface) && val.IsNil() {
+			return nil
+		}
+		return val.Interface()
+	}
 	switch val.Kind() {
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
 		return val.Int()
@@ -25,9 +39,55 @@ func GetRealValue(val *reflect.Value) interface{} {
 		return val.Uint()
 	case reflect.Float32, reflect.Float64:
 		return val.Float()
-	case reflect.Slice, reflect.Map:
+	case reflect.Slice, reflect.Map, reflect.Struct, reflect.Array:
+		return val.Interface()
+	case reflect.Ptr, reflect.Interface:
+		if val.IsNil() {
+			return nil
+		}
 		return val.Interface()
 	default:
 		return nil
 	}
 }
+
+// reflectionResult returns result of reflected function call and error
+// returned as its last value. Multiple results are returned as a list.
+func reflectionResult(res []reflect.Value) (interface{}, error) {
+	if n := len(res); n > 0 && res[n-1].Type() == errorType {
+		if !res[n-1].IsNil() {
+			return nil, res[n-1].Interface().(error)
+		}
+		res = res[:n-1]
+	}
+	switch len(res) {
+	case 0:
+		return nil, nil
+	case 1:
+		return GetRealValue(&res[0]), nil
+	}
+	values := make([]interface{}, len(res))
+	for i := range res {
+		values[i] = GetRealValue(&res[i])
+	}
+	return values, nil
+}
+
+// getTaskResultMessage returns result message of task which returned val and err.
+// Task error is stored as FAILURE result, arguments which do not match task
+// parameters as FAILURE with TypeError like celery does. Only error of task
+// which rejected its message is returned so that no result is stored.
+func getTaskResultMessage(val interface{}, err error) (*ResultMessage, error) {
+	if err == nil {
+		return getResultMessage(val), nil
+	}
+	var rejectErr *RejectError
+	if errors.As(err, &rejectErr) {
+		return nil, err
+	}
+	var argErr *ArgumentError
+	if errors.As(err, &argErr) {
+		return getExceptionResultMessage("TypeError", argErr.Err), nil
+	}
+	return getFailureResultMessage(err), nil
+}
@@ -0,0 +1,60 @@
+// Copyright (c) 2019 Sick Yoon
+// This file is part of gocelery which is released under MIT license.
+// See file LICENSE for full license details.
+
+package gocelery
+
+import (
+	"fmt"
+)
+
+// Delivery is handle on message fetched from broker which stays
+// owned by the broker until it is acknowledged (acks_late)
+type Delivery interface {
+	// Ack tells broker that the message has been processed
+	Ack() error
+	// Nack gives the message back to broker, requeueing it if requested
+	Nack(requeue bool) error
+}
+
+// RejectError is returned by task to reject its message instead of acknowledging it.
+// It only takes effect when message was fetched with late acknowledgement.
+type RejectError struct {
+	Reason  error
+	Requeue bool
+}
+
+// NewRejectError creates new RejectError
+func NewRejectError(reason error, requeue bool) *RejectError {
+	return &RejectError{
+		Reason:  reason,
+		Requeue: requeue,
+	}
+}
+
+func (e *RejectError) Error() string {
+	return fmt.Sprintf("task rejected (requeue: %t): %v", e.Requeue, e.Reason)
+}
+
+// Unwrap returns reason of rejection
+func (e *RejectError) Unwrap() error {
+	return e.Reason
+}
+
+// unackedRestorer is implemented by brokers which need to be told
+// to restore messages whose visibility timeout has passed
+type unackedRestorer interface {
+	RestoreUnacked() (int, error)
+}
+
+// delayedMover is implemented by brokers which keep messages with future ETA
+// aside and need to be told to move due messages to their queues
+type delayedMover interface {
+	MoveDelayed() (int, error)
+}
+
+// consumerStopper is implemented by brokers which prefetch messages
+// and need to give them back when worker stops
+type consumerStopper interface {
+	StopConsuming() error
+}
@@ -0,0 +1,73 @@
+// Copyright (c) 2019 Sick Yoon
+// This file is part of gocelery which is released under MIT license.
+// See file LICENSE for full license details.
+
+package gocelery
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// CeleryEnvelope is message fetched from broker before its protocol version is known
+type CeleryEnvelope struct {
+	Body            string                 `json:"body"`
+	Headers         map[string]interface{} `json:"headers"`
+	Properties      CeleryPropertiesV2     `json:"properties"`
+	ContentType     string                 `json:"content-type"`
+	ContentEncoding string                 `json:"content-encoding"`
+
+	// Delivery settles message fetched with late acknowledgement.
+	// It is nil when broker acknowledged message on receive.
+	Delivery Delivery `json:"-"`
+}
+
+// CeleryEnvelopeBroker is interface for celery broker able to fetch
+// messages of either protocol version with a single pop
+type CeleryEnvelopeBroker interface {
+	GetCeleryEnvelope() (*CeleryEnvelope, error) // must be non-blocking
+}
+
+// IsV2 reports whether message uses protocol v2, which carries task name in headers
+func (ce *CeleryEnvelope) IsV2() bool {
+	_, ok := ce.Headers["task"]
+	return ok
+}
+
+// GetCeleryMessageV2 converts envelope to protocol v2 message
+func (ce *CeleryEnvelope) GetCeleryMessageV2() (*CeleryMessageV2, error) {
+	if !ce.IsV2() {
+		return nil, fmt.Errorf("not a protocol v2 message")
+	}
+	headerBytes, err := json.Marshal(ce.Headers)
+	if err != nil {
+		return nil, err
+	}
+	celeryMessage := &CeleryMessageV2{
+		Body:            ce.Body,
+		Properties:      ce.Properties,
+		ContentType:     ce.ContentType,
+		ContentEncoding: ce.ContentEncoding,
+	}
+	if err := json.Unmarshal(headerBytes, &celeryMessage.Headers); err != nil {
+		return nil, err
+	}
+	return celeryMessage, nil
+}
+
+// GetTaskMessage decodes protocol v1 task message from envelope body
+func (ce *CeleryEnvelope) GetTaskMessage() (*TaskMessage, error) {
+	celeryMessage := &CeleryMessage{
+		Body:            ce.Body,
+		ContentType:     ce.ContentType,
+		ContentEncoding: ce.ContentEncoding,
+		Properties: CeleryProperties{
+			BodyEncoding: ce.Properties.BodyEncoding,
+		},
+	}
+	taskMessage := celeryMessage.GetTaskMessage()
+	if taskMessage == nil {
+		return nil, fmt.Errorf("failed to decode protocol v1 message")
+	}
+	return taskMessage, nil
+}
@@ -0,0 +1,111 @@
+// Copyright (c) 2019 Sick Yoon
+// This file is part of gocelery which is released under MIT license.
+// See file LICENSE for full license details.
+
+package gocelery
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+func makeEnvelope(t *testing.T, message interface{}) *CeleryEnvelope {
+	messageBytes, err := json.Marshal(message)
+	if err != nil {
+		t.Fatalf("failed to marshal message: %v", err)
+	}
+	var envelope CeleryEnvelope
+	if err := json.Unmarshal(messageBytes, &envelope); err != nil {
+		t.Fatalf("failed to unmarshal envelope: %v", err)
+	}
+	return &envelope
+}
+
+// TestEnvelopeDetectsProtocol tests that v1 and v2 messages are told apart from one fetch
+func TestEnvelopeDetectsProtocol(t *testing.T) {
+	celeryMessage, err := makeCeleryMessage()
+	if err != nil {
+		t.Fatalf("failed to construct celery message: %v", err)
+	}
+	defer releaseCeleryMessage(celeryMessage)
+	envelopeV1 := makeEnvelope(t, celeryMessage)
+	if envelopeV1.IsV2() {
+		t.Error("v1 message detected as v2")
+	}
+	if taskMessage, err := envelopeV1.GetTaskMessage(); err != nil || taskMessage.Task != "add" {
+		t.Errorf("failed to decode v1 message: %v", err)
+	}
+
+	taskMessage := getTaskMessageV2(1, 2)
+	defer releaseTaskMessageV2(taskMessage)
+	encoded, _ := taskMessage.Encode()
+	headers := buildCeleryHeadersV2("add", taskMessage.Args, nil)
+	defer releaseCeleryMessageHeadersV2(headers)
+	celeryMessageV2 := getCeleryMessageV2(encoded, *headers)
+	defer releaseCeleryMessageV2(celeryMessageV2)
+	envelopeV2 := makeEnvelope(t, celeryMessageV2)
+	if !envelopeV2.IsV2() {
+		t.Error("v2 message detected as v1")
+	}
+	decoded, err := envelopeV2.GetCeleryMessageV2()
+	if err != nil || decoded.Headers.ID != headers.ID || decoded.GetTaskMessageV2() == nil {
+		t.Errorf("failed to decode v2 message: %v", err)
+	}
+}
+
+// TestEnvelopeAMQPv1 tests that raw v1 body published on AMQP is decoded
+func TestEnvelopeAMQPv1(t *testing.T) {
+	taskMessage := getTaskMessage("add")
+	defer releaseTaskMessage(taskMessage)
+	taskMessage.Args = []interface{}{1.0, 2.0}
+	body, _ := json.Marshal(taskMessage)
+	envelope := newCeleryEnvelopeFromDelivery(amqp.Delivery{
+		ContentType: "application/json",
+		Body:        body,
+	})
+	if envelope.IsV2() {
+		t.Fatal("v1 AMQP message detected as v2")
+	}
+	decoded, err := envelope.GetTaskMessage()
+	if err != nil || decoded.ID != taskMessage.ID {
+		t.Errorf("failed to decode v1 AMQP message: %v", err)
+	}
+}
+
+// TestWorkerHandleEnvelope tests that messages of both versions reach their runner
+func TestWorkerHandleEnvelope(t *testing.T) {
+	backend := &stubBackend{}
+	celeryWorker := NewCeleryWorker(&stubBroker{}, backend, 1)
+	celeryWorker.Register("add", add)
+
+	taskMessage := getTaskMessage("add")
+	taskMessage.Args = []interface{}{1, 2}
+	taskID := taskMessage.ID
+	encoded, _ := taskMessage.Encode()
+	releaseTaskMessage(taskMessage)
+	celeryMessage := getCeleryMessage(encoded)
+	defer releaseCeleryMessage(celeryMessage)
+	delivery := &recordingDelivery{}
+	envelope := makeEnvelope(t, celeryMessage)
+	envelope.Delivery = delivery
+	celeryWorker.handleEnvelope(context.Background(), envelope)
+	if _, err := backend.GetResult(taskID); err != nil {
+		t.Errorf("v1 task was not executed: %v", err)
+	}
+	if acked, _, _ := delivery.settled(); !acked {
+		t.Error("v1 delivery was not acknowledged")
+	}
+
+	taskMessageV2 := getTaskMessageV2(3, 4)
+	encodedV2, _ := taskMessageV2.Encode()
+	headers := buildCeleryHeadersV2("add", taskMessageV2.Args, nil)
+	releaseTaskMessageV2(taskMessageV2)
+	celeryMessageV2 := getCeleryMessageV2(encodedV2, *headers)
+	celeryWorker.handleEnvelope(context.Background(), makeEnvelope(t, celeryMessageV2))
+	if res, err := backend.GetResult(headers.ID); err != nil || res.Result != int64(7) {
+		t.Errorf("v2 task was not executed: %v %v", res, err)
+	}
+}
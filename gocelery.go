@@ -6,15 +6,18 @@ package gocelery
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sync"
 	"time"
 )
 
 // CeleryClient provides API for sending celery tasks
 type CeleryClient struct {
-	broker  CeleryBroker
-	backend CeleryBackend
-	worker  *CeleryWorker
+	broker       CeleryBroker
+	backend      CeleryBackend
+	worker       *CeleryWorker
+	pollStrategy *PollStrategy
 }
 
 // CeleryBroker is interface for celery broker database
@@ -31,12 +34,29 @@ type CeleryBackend interface {
 	SetResult(taskID string, result *ResultMessage) error
 }
 
+// CeleryReplyBackend is interface for celery backend delivering results
+// to reply queue of client which sent the task (rpc://)
+type CeleryReplyBackend interface {
+	// ReplyTo returns name of queue results of tasks sent by client are delivered to
+	ReplyTo() string
+	SetReplyResult(replyTo string, taskID string, result *ResultMessage) error
+}
+
+// ErrReplyBackendV1 is returned when protocol v1 task is sent with rpc backend
+var ErrReplyBackendV1 = errors.New("rpc backend requires protocol v2, use DelayV2 or ApplyAsync")
+
+// resultForgetter is implemented by backends keeping results in memory,
+// results are forgotten once AsyncResult has read them
+type resultForgetter interface {
+	Forget(taskID string)
+}
+
 // NewCeleryClient creates new celery client
 func NewCeleryClient(broker CeleryBroker, backend CeleryBackend, numWorkers int) (*CeleryClient, error) {
 	return &CeleryClient{
-		broker,
-		backend,
-		NewCeleryWorker(broker, backend, numWorkers),
+		broker:  broker,
+		backend: backend,
+		worker:  NewCeleryWorker(broker, backend, numWorkers),
 	}, nil
 }
 
@@ -45,6 +65,36 @@ func (cc *CeleryClient) Register(name string, task interface{}) {
 	cc.worker.Register(name, task)
 }
 
+// RegisterParams registers function task with declared parameter names
+func (cc *CeleryClient) RegisterParams(name string, fn interface{}, params ...TaskParam) error {
+	return cc.worker.RegisterParams(name, fn, params...)
+}
+
+// RegisterFactory registers task created by factory for every execution
+func (cc *CeleryClient) RegisterFactory(name string, factory CeleryTaskFactory) {
+	cc.worker.RegisterFactory(name, factory)
+}
+
+// SetRateLimiter sets limiter used to enforce task rate limits
+func (cc *CeleryClient) SetRateLimiter(limiter RateLimiter) {
+	cc.worker.SetRateLimiter(limiter)
+}
+
+// SetRateLimit declares shared quota for task
+func (cc *CeleryClient) SetRateLimit(name string, limit *RateLimit) error {
+	return cc.worker.SetRateLimit(name, limit)
+}
+
+// SetUseNumber makes worker decode numeric arguments as json.Number
+func (cc *CeleryClient) SetUseNumber(useNumber bool) {
+	cc.worker.SetUseNumber(useNumber)
+}
+
+// SetPollStrategy sets how results returned by client poll backend
+func (cc *CeleryClient) SetPollStrategy(strategy *PollStrategy) {
+	cc.pollStrategy = strategy
+}
+
 // StartWorkerWithContext starts celery workers with given parent context
 func (cc *CeleryClient) StartWorkerWithContext(ctx context.Context) {
 	cc.worker.StartWorkerWithContext(ctx)
@@ -81,6 +131,10 @@ func (cc *CeleryClient) DelayKwargs(task string, args map[string]interface{}) (*
 
 func (cc *CeleryClient) delay(task *TaskMessage) (*AsyncResult, error) {
 	defer releaseTaskMessage(task)
+	// protocol v1 messages have no reply_to, so rpc results would never arrive
+	if _, ok := cc.backend.(CeleryReplyBackend); ok {
+		return nil, ErrReplyBackendV1
+	}
 	encodedMessage, err := task.Encode()
 	if err != nil {
 		return nil, err
@@ -92,8 +146,9 @@ func (cc *CeleryClient) delay(task *TaskMessage) (*AsyncResult, error) {
 		return nil, err
 	}
 	return &AsyncResult{
-		TaskID:  task.ID,
-		backend: cc.backend,
+		TaskID:       task.ID,
+		backend:      cc.backend,
+		PollStrategy: cc.pollStrategy,
 	}, nil
 }
 
@@ -110,37 +165,150 @@ type CeleryTask interface {
 	RunTask() (interface{}, error)
 }
 
+// CeleryTaskFactory creates new CeleryTask for every execution.
+// Registered CeleryTask is shared by all worker goroutines,
+// so tasks keeping parsed kwargs in their fields should be registered by factory.
+type CeleryTaskFactory func() CeleryTask
+
+// PollStrategy controls how often waiting AsyncResult polls backend.
+// Interval grows by Backoff factor after every unsuccessful poll up to MaxInterval.
+type PollStrategy struct {
+	Interval    time.Duration
+	Backoff     float64
+	MaxInterval time.Duration
+}
+
+// DefaultPollStrategy polls backend every 50 milliseconds
+var DefaultPollStrategy = &PollStrategy{
+	Interval: 50 * time.Millisecond,
+	Backoff:  1,
+}
+
+// minPollInterval keeps zero or tiny intervals from polling backend in a busy loop
+const minPollInterval = 10 * time.Millisecond
+
+// first returns interval before the first poll
+func (ps *PollStrategy) first() time.Duration {
+	if ps.Interval < minPollInterval {
+		return minPollInterval
+	}
+	return ps.Interval
+}
+
+// next returns interval following given one
+func (ps *PollStrategy) next(interval time.Duration) time.Duration {
+	if ps.Backoff > 1 {
+		interval = time.Duration(float64(interval) * ps.Backoff)
+	}
+	if ps.MaxInterval > 0 && interval > ps.MaxInterval {
+		interval = ps.MaxInterval
+	}
+	if interval < minPollInterval {
+		interval = minPollInterval
+	}
+	return interval
+}
+
 // AsyncResult represents pending result
 type AsyncResult struct {
 	TaskID  string
 	backend CeleryBackend
 	result  *ResultMessage
+	// PollStrategy is used while waiting for result, DefaultPollStrategy when nil
+	PollStrategy *PollStrategy
+
+	lock     sync.Mutex
+	doneOnce sync.Once
+	done     chan struct{}
+}
+
+// resultWaiter is implemented by backends which notify about stored results,
+// so that waiting results do not need to poll backend frequently
+type resultWaiter interface {
+	WaitResult(taskID string) (<-chan struct{}, func(), error)
 }
 
 // Get gets actual result from backend
 // It blocks for period of time set by timeout and returns error if unavailable
 func (ar *AsyncResult) Get(timeout time.Duration) (interface{}, error) {
-	ticker := time.NewTicker(50 * time.Millisecond)
-	timeoutChan := time.After(timeout)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	val, err := ar.GetWithContext(ctx)
+	if err == context.DeadlineExceeded {
+		return nil, fmt.Errorf("%v timeout getting result for %s", timeout, ar.TaskID)
+	}
+	return val, err
+}
+
+// GetWithContext gets actual result from backend
+// It blocks until result is available or ctx is done and returns ctx error in the latter case.
+// Task which failed is reported by TaskFailedError.
+func (ar *AsyncResult) GetWithContext(ctx context.Context) (interface{}, error) {
+	strategy := ar.PollStrategy
+	if strategy == nil {
+		strategy = DefaultPollStrategy
+	}
+	interval := strategy.first()
+	var notify <-chan struct{}
+	if waiter, ok := ar.backend.(resultWaiter); ok {
+		ch, cancel, err := waiter.WaitResult(ar.TaskID)
+		if err == nil {
+			defer cancel()
+			notify = ch
+			// polling is only fallback for missed notifications
+			if interval < time.Second {
+				interval = time.Second
+			}
+			if val, err := ar.AsyncGet(); err == nil {
+				return val, nil
+			} else if _, failed := err.(*TaskFailedError); failed {
+				return nil, err
+			}
+		}
+	}
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
 	for {
 		select {
-		case <-timeoutChan:
-			err := fmt.Errorf("%v timeout getting result for %s", timeout, ar.TaskID)
-			return nil, err
-		case <-ticker.C:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-notify:
 			val, err := ar.AsyncGet()
-			if err != nil {
+			if _, failed := err.(*TaskFailedError); err != nil && !failed {
 				continue
 			}
-			return val, nil
+			return val, err
+		case <-timer.C:
+			val, err := ar.AsyncGet()
+			if _, failed := err.(*TaskFailedError); err == nil || failed {
+				return val, err
+			}
+			interval = strategy.next(interval)
+			timer.Reset(interval)
 		}
 	}
 }
 
+// Done returns channel which is closed once result is ready, successful or failed.
+// All calls share one channel fed by single background waiter, which runs until result is ready.
+// Use GetWithContext to wait for result which may never be ready.
+func (ar *AsyncResult) Done() <-chan struct{} {
+	ar.doneOnce.Do(func() {
+		ar.done = make(chan struct{})
+		go func() {
+			defer close(ar.done)
+			ar.GetWithContext(context.Background())
+		}()
+	})
+	return ar.done
+}
+
 // AsyncGet gets actual result from backend and returns nil if not available
 func (ar *AsyncResult) AsyncGet() (interface{}, error) {
+	ar.lock.Lock()
+	defer ar.lock.Unlock()
 	if ar.result != nil {
-		return ar.result.Result, nil
+		return ar.resultValue(ar.result)
 	}
 	val, err := ar.backend.GetResult(ar.TaskID)
 	if err != nil {
@@ -149,15 +317,36 @@ func (ar *AsyncResult) AsyncGet() (interface{}, error) {
 	if val == nil {
 		return nil, err
 	}
+	if isReadyStatus(val.Status) {
+		ar.cacheResult(val)
+	}
+	return ar.resultValue(val)
+}
+
+// resultValue returns value of successful result or error of failed or pending one
+func (ar *AsyncResult) resultValue(val *ResultMessage) (interface{}, error) {
 	if val.Status != "SUCCESS" {
+		if err := newTaskFailedError(ar.TaskID, val); err != nil {
+			return nil, err
+		}
 		return nil, fmt.Errorf("error response status %v", val)
 	}
-	ar.result = val
 	return val.Result, nil
 }
 
+// isReadyStatus reports whether task in status has finished
+func isReadyStatus(status string) bool {
+	switch status {
+	case "SUCCESS", "FAILURE", "REVOKED":
+		return true
+	}
+	return false
+}
+
 // Ready checks if actual result is ready
 func (ar *AsyncResult) Ready() (bool, error) {
+	ar.lock.Lock()
+	defer ar.lock.Unlock()
 	if ar.result != nil {
 		return true, nil
 	}
@@ -165,6 +354,18 @@ func (ar *AsyncResult) Ready() (bool, error) {
 	if err != nil {
 		return false, err
 	}
+	// pending and started results are not cached so that they are fetched again
+	if val == nil || !isReadyStatus(val.Status) {
+		return false, nil
+	}
+	ar.cacheResult(val)
+	return true, nil
+}
+
+// cacheResult keeps finished result and lets backend forget it
+func (ar *AsyncResult) cacheResult(val *ResultMessage) {
 	ar.result = val
-	return (val != nil), nil
+	if forgetter, ok := ar.backend.(resultForgetter); ok {
+		forgetter.Forget(ar.TaskID)
+	}
 }
@@ -5,6 +5,8 @@
 package gocelery
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"reflect"
 	"strconv"
@@ -1448,3 +1450,106 @@ func convertInterfaceToStringMap(i interface{}) map[string]string {
 	}
 	return stringMap
 }
+
+// TestApplyAsyncOptions tests that execution options are set on sent message
+func TestApplyAsyncOptions(t *testing.T) {
+	broker := &stubBroker{}
+	client, _ := NewCeleryClient(broker, &stubBackend{}, 1)
+	expires := time.Now().Add(time.Hour)
+	_, err := client.ApplyAsync("add", []interface{}{1, 2}, nil, &ApplyOptions{
+		Queue:     "low",
+		Priority:  6,
+		Countdown: time.Minute,
+		Expires:   expires,
+	})
+	if err != nil {
+		t.Fatalf("failed to apply task: %v", err)
+	}
+	if len(broker.sentV2) != 1 {
+		t.Fatalf("expected 1 sent message, got %d", len(broker.sentV2))
+	}
+	message := broker.sentV2[0]
+	if message.Properties.DeliveryInfo.RoutingKey != "low" || message.Properties.Priority != 6 {
+		t.Errorf("routing options are not applied: %+v", message.Properties)
+	}
+	eta, ok := parseETA(message.Headers.Eta)
+	if !ok || eta.Before(time.Now().Add(59*time.Second)) {
+		t.Errorf("countdown is not applied to eta: %v", message.Headers.Eta)
+	}
+	if message.Headers.Expires != formatETA(expires) {
+		t.Errorf("expires is not applied: %v", message.Headers.Expires)
+	}
+}
+
+// TestAsyncResultWithContext tests that waiting for result stops with its context
+func TestAsyncResultWithContext(t *testing.T) {
+	backend := &stubBackend{}
+	asyncResult := &AsyncResult{
+		TaskID:       uuid.New().String(),
+		backend:      backend,
+		PollStrategy: &PollStrategy{Interval: time.Millisecond, Backoff: 2, MaxInterval: 20 * time.Millisecond},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := asyncResult.GetWithContext(ctx); err != context.DeadlineExceeded {
+		t.Errorf("expected deadline exceeded, got %v", err)
+	}
+
+	done := asyncResult.Done()
+	if asyncResult.Done() != done {
+		t.Error("done channel is not shared by calls")
+	}
+	select {
+	case <-done:
+		t.Fatal("done before result is stored")
+	default:
+	}
+	backend.SetResult(asyncResult.TaskID, &ResultMessage{Status: "SUCCESS", Result: 3})
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("done is not closed after result is stored")
+	}
+	if val, err := asyncResult.AsyncGet(); err != nil || val != 3 {
+		t.Errorf("unexpected result %v: %v", val, err)
+	}
+}
+
+// TestAsyncResultReadyFailure tests that failed result stays failed once it is cached by Ready
+func TestAsyncResultReadyFailure(t *testing.T) {
+	backend := &stubBackend{}
+	asyncResult := &AsyncResult{TaskID: uuid.New().String(), backend: backend}
+	backend.SetResult(asyncResult.TaskID, &ResultMessage{Status: "STARTED"})
+	if ready, err := asyncResult.Ready(); ready || err != nil {
+		t.Errorf("started task should not be ready: %v", err)
+	}
+	backend.SetResult(asyncResult.TaskID, &ResultMessage{Status: "FAILURE", Result: "boom"})
+	if ready, err := asyncResult.Ready(); !ready || err != nil {
+		t.Errorf("failed task should be ready: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		var failedErr *TaskFailedError
+		if _, err := asyncResult.AsyncGet(); !errors.As(err, &failedErr) {
+			t.Errorf("expected task failed error, got %v", err)
+		}
+	}
+}
+
+// TestPollStrategyBackoff tests that poll interval grows up to its cap
+func TestPollStrategyBackoff(t *testing.T) {
+	strategy := &PollStrategy{Interval: 10 * time.Millisecond, Backoff: 2, MaxInterval: 30 * time.Millisecond}
+	interval := strategy.Interval
+	for _, expected := range []time.Duration{20 * time.Millisecond, 30 * time.Millisecond, 30 * time.Millisecond} {
+		interval = strategy.next(interval)
+		if interval != expected {
+			t.Errorf("expected interval %v, got %v", expected, interval)
+		}
+	}
+	if interval := DefaultPollStrategy.next(50 * time.Millisecond); interval != 50*time.Millisecond {
+		t.Errorf("default strategy should poll at constant interval, got %v", interval)
+	}
+	zero := &PollStrategy{}
+	if interval := zero.next(zero.first()); interval != minPollInterval {
+		t.Errorf("zero interval should be raised to %v, got %v", minPollInterval, interval)
+	}
+}
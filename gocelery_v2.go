@@ -1,20 +1,46 @@
 package gocelery
 
+import (
+	"time"
+)
+
 // DelayV2 gets asynchronous result
 func (cc *CeleryClient) DelayV2(task string, args ...interface{}) (*AsyncResult, error) {
 	celeryTask := getTaskMessageV2(args...)
 	headers := buildCeleryHeadersV2(task, args, nil)
-	return cc.delayV2(celeryTask, headers)
+	return cc.delayV2(celeryTask, headers, nil)
 }
 
 // DelayKwargsV2 gets asynchronous result with kwargs support
 func (cc *CeleryClient) DelayKwargsV2(task string, kwargs map[string]interface{}, args ...interface{}) (*AsyncResult, error) {
 	celeryTask := getTaskMessageV2WithKwargs(args, kwargs)
 	headers := buildCeleryHeadersV2(task, args, kwargs)
-	return cc.delayV2(celeryTask, headers)
+	return cc.delayV2(celeryTask, headers, nil)
+}
+
+// ApplyOptions are execution options of task sent by ApplyAsync
+type ApplyOptions struct {
+	// Queue routes task to given queue instead of the default one
+	Queue string
+	// Priority of task message. On redis 0 is the highest priority
+	// and it is rounded down to the broker priority steps.
+	Priority int
+	// ETA is the earliest time task is executed at
+	ETA time.Time
+	// Countdown delays execution by given duration, it is ignored when ETA is set
+	Countdown time.Duration
+	// Expires is the time after which task is no longer executed
+	Expires time.Time
 }
 
-func (cc *CeleryClient) delayV2(task *TaskMessageV2, headers *CeleryHeadersV2) (*AsyncResult, error) {
+// ApplyAsync gets asynchronous result of task sent with execution options
+func (cc *CeleryClient) ApplyAsync(task string, args []interface{}, kwargs map[string]interface{}, options *ApplyOptions) (*AsyncResult, error) {
+	celeryTask := getTaskMessageV2WithKwargs(args, kwargs)
+	headers := buildCeleryHeadersV2(task, args, kwargs)
+	return cc.delayV2(celeryTask, headers, options)
+}
+
+func (cc *CeleryClient) delayV2(task *TaskMessageV2, headers *CeleryHeadersV2, options *ApplyOptions) (*AsyncResult, error) {
 	defer releaseTaskMessageV2(task)
 	defer releaseCeleryMessageHeadersV2(headers)
 
@@ -26,12 +52,32 @@ func (cc *CeleryClient) delayV2(task *TaskMessageV2, headers *CeleryHeadersV2) (
 	celeryMessage := getCeleryMessageV2(encodedTaskMessage, *headers)
 
 	defer releaseCeleryMessageV2(celeryMessage)
+	if replyBackend, ok := cc.backend.(CeleryReplyBackend); ok {
+		celeryMessage.Properties.ReplyTo = replyBackend.ReplyTo()
+	}
+	if options != nil {
+		if options.Queue != "" {
+			celeryMessage.Properties.DeliveryInfo.RoutingKey = options.Queue
+		}
+		celeryMessage.Properties.Priority = options.Priority
+		eta := options.ETA
+		if eta.IsZero() && options.Countdown > 0 {
+			eta = time.Now().Add(options.Countdown)
+		}
+		if !eta.IsZero() {
+			celeryMessage.Headers.Eta = formatETA(eta)
+		}
+		if !options.Expires.IsZero() {
+			celeryMessage.Headers.Expires = formatETA(options.Expires)
+		}
+	}
 	err = cc.broker.SendCeleryMessageV2(celeryMessage)
 	if err != nil {
 		return nil, err
 	}
 	return &AsyncResult{
-		TaskID:  headers.ID,
-		backend: cc.backend,
+		TaskID:       headers.ID,
+		backend:      cc.backend,
+		PollStrategy: cc.pollStrategy,
 	}, nil
 }
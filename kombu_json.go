@@ -0,0 +1,303 @@
+// Copyright (c) 2019 Sick Yoon
+// This file is part of gocelery which is released under MIT license.
+// See file LICENSE for full license details.
+
+package gocelery
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+// kombu json encodes types unknown to json as {"__type__": type, "__value__": value}
+const (
+	kombuTypeKey  = "__type__"
+	kombuValueKey = "__value__"
+)
+
+const (
+	kombuDateFormat = "2006-01-02"
+	kombuTimeFormat = "15:04:05.000000"
+)
+
+var decimalType = reflect.TypeOf(Decimal(""))
+
+// DateTime is time exchanged with python as datetime.datetime.
+// time.Time is encoded with kombu marker only as task argument or result itself,
+// so struct fields use DateTime to keep the marker.
+type DateTime struct {
+	time.Time
+}
+
+// MarshalJSON encodes time with kombu datetime marker
+func (t DateTime) MarshalJSON() ([]byte, error) {
+	return json.Marshal(kombuMarker("datetime", t.Format(isoTimeFormat)))
+}
+
+// UnmarshalJSON decodes time from kombu datetime marker or iso string
+func (t *DateTime) UnmarshalJSON(data []byte) error {
+	value, err := unmarshalKombuValue(data, "datetime")
+	if err != nil {
+		return err
+	}
+	parsed, err := parseISOTime(value, "2006-01-02T15:04:05Z07:00", "2006-01-02T15:04:05")
+	if err != nil {
+		return err
+	}
+	t.Time = parsed
+	return nil
+}
+
+// Date is calendar date exchanged with python as datetime.date
+type Date struct {
+	time.Time
+}
+
+// NewDate returns Date of year, month and day
+func NewDate(year int, month time.Month, day int) Date {
+	return Date{Time: time.Date(year, month, day, 0, 0, 0, 0, time.UTC)}
+}
+
+// String returns date in iso format
+func (d Date) String() string {
+	return d.Format(kombuDateFormat)
+}
+
+// MarshalJSON encodes date with kombu date marker
+func (d Date) MarshalJSON() ([]byte, error) {
+	return json.Marshal(kombuMarker("date", d.String()))
+}
+
+// UnmarshalJSON decodes date from kombu date marker or iso string
+func (d *Date) UnmarshalJSON(data []byte) error {
+	value, err := unmarshalKombuValue(data, "date")
+	if err != nil {
+		return err
+	}
+	t, err := time.Parse(kombuDateFormat, value)
+	if err != nil {
+		return err
+	}
+	d.Time = t
+	return nil
+}
+
+// TimeOfDay is wall clock time exchanged with python as datetime.time.
+// Its date part is ignored.
+type TimeOfDay struct {
+	time.Time
+}
+
+// NewTimeOfDay returns TimeOfDay of hour, minute, second and nanosecond in loc
+func NewTimeOfDay(hour, min, sec, nsec int, loc *time.Location) TimeOfDay {
+	return TimeOfDay{Time: time.Date(0, time.January, 1, hour, min, sec, nsec, loc)}
+}
+
+// String returns time in iso format with microseconds
+func (t TimeOfDay) String() string {
+	return t.Format(kombuTimeFormat)
+}
+
+// MarshalJSON encodes time with kombu time marker
+func (t TimeOfDay) MarshalJSON() ([]byte, error) {
+	value := t.String()
+	// naive python time has no offset
+	if t.Location() != time.UTC {
+		value = t.Format(kombuTimeFormat + "-07:00")
+	}
+	return json.Marshal(kombuMarker("time", value))
+}
+
+// UnmarshalJSON decodes time from kombu time marker or iso string
+func (t *TimeOfDay) UnmarshalJSON(data []byte) error {
+	value, err := unmarshalKombuValue(data, "time")
+	if err != nil {
+		return err
+	}
+	parsed, err := parseISOTime(value, "15:04:05Z07:00", "15:04:05")
+	if err != nil {
+		return err
+	}
+	t.Time = parsed
+	return nil
+}
+
+// Decimal is decimal number exchanged with python as decimal.Decimal.
+// It is kept in its string form so that no precision is lost.
+type Decimal string
+
+// MarshalJSON encodes decimal with kombu decimal marker
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return json.Marshal(kombuMarker("decimal", string(d)))
+}
+
+// UnmarshalJSON decodes decimal from kombu decimal marker, string or number
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+	var number json.Number
+	if err := json.Unmarshal(data, &number); err == nil {
+		*d = Decimal(number)
+		return nil
+	}
+	value, err := unmarshalKombuValue(data, "decimal")
+	if err != nil {
+		return err
+	}
+	*d = Decimal(value)
+	return nil
+}
+
+// kombuMarker returns value with kombu type marker
+func kombuMarker(typeName string, value interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		kombuTypeKey:  typeName,
+		kombuValueKey: value,
+	}
+}
+
+// unmarshalKombuValue returns string value of kombu marker of typeName or plain json string
+func unmarshalKombuValue(data []byte, typeName string) (string, error) {
+	var value string
+	if err := json.Unmarshal(data, &value); err == nil {
+		return value, nil
+	}
+	var marker struct {
+		Type  string `json:"__type__"`
+		Value string `json:"__value__"`
+	}
+	if err := json.Unmarshal(data, &marker); err != nil {
+		return "", err
+	}
+	if marker.Type != typeName {
+		return "", fmt.Errorf("expected %s, got %s", typeName, marker.Type)
+	}
+	return marker.Value, nil
+}
+
+// parseISOTime parses python isoformat output, naive values are in utc
+func parseISOTime(value string, layouts ...string) (time.Time, error) {
+	var err error
+	for _, layout := range layouts {
+		var t time.Time
+		if t, err = time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, err
+}
+
+// kombuEncode returns copy of v in which time.Time, uuid.UUID and []byte
+// are replaced with kombu type markers. Only v itself and items of
+// []interface{} and map[string]interface{} are replaced.
+func kombuEncode(v interface{}) interface{} {
+	switch val := v.(type) {
+	case nil:
+		return nil
+	case time.Time:
+		return kombuMarker("datetime", val.Format(isoTimeFormat))
+	case uuid.UUID:
+		return kombuMarker("uuid", map[string]interface{}{"hex": hex.EncodeToString(val[:])})
+	case []byte:
+		if val == nil {
+			return nil
+		}
+		// kombu sends bytes which are not utf-8 as base64
+		if utf8.Valid(val) {
+			return kombuMarker("bytes", string(val))
+		}
+		return kombuMarker("base64", base64.StdEncoding.EncodeToString(val))
+	case []interface{}:
+		if val == nil {
+			return val
+		}
+		items := make([]interface{}, len(val))
+		for i, item := range val {
+			items[i] = kombuEncode(item)
+		}
+		return items
+	case map[string]interface{}:
+		if val == nil {
+			return val
+		}
+		items := make(map[string]interface{}, len(val))
+		for key, item := range val {
+			items[key] = kombuEncode(item)
+		}
+		return items
+	}
+	// structs and other values are left to encoding/json, struct fields
+	// use DateTime, Date, TimeOfDay or Decimal to be encoded with markers
+	return v
+}
+
+// kombuDecode replaces kombu type markers in decoded json value v with go values:
+// datetime as time.Time, date as Date, time as TimeOfDay, decimal as Decimal,
+// uuid as uuid.UUID and bytes as []byte. Unknown markers are kept as maps.
+func kombuDecode(v interface{}) (interface{}, error) {
+	switch val := v.(type) {
+	case []interface{}:
+		for i, item := range val {
+			decoded, err := kombuDecode(item)
+			if err != nil {
+				return nil, err
+			}
+			val[i] = decoded
+		}
+	case map[string]interface{}:
+		if typeName, ok := val[kombuTypeKey].(string); ok && len(val) == 2 {
+			if value, ok := val[kombuValueKey]; ok {
+				return kombuDecodeMarker(typeName, value, val)
+			}
+		}
+		for key, item := range val {
+			decoded, err := kombuDecode(item)
+			if err != nil {
+				return nil, err
+			}
+			val[key] = decoded
+		}
+	}
+	return v, nil
+}
+
+// kombuDecodeMarker decodes value of kombu marker of typeName
+func kombuDecodeMarker(typeName string, value interface{}, marker map[string]interface{}) (interface{}, error) {
+	switch typeName {
+	case "datetime", "date", "time", "decimal", "uuid", "bytes", "base64":
+	default:
+		return marker, nil
+	}
+	if typeName == "uuid" {
+		// kombu sends uuid as {"hex": value}
+		if fields, ok := value.(map[string]interface{}); ok {
+			value = fields["hex"]
+		}
+	}
+	s, ok := value.(string)
+	if !ok {
+		return nil, fmt.Errorf("invalid %s value %v", typeName, value)
+	}
+	switch typeName {
+	case "datetime":
+		return parseISOTime(s, "2006-01-02T15:04:05Z07:00", "2006-01-02T15:04:05")
+	case "date":
+		t, err := time.Parse(kombuDateFormat, s)
+		return Date{Time: t}, err
+	case "time":
+		t, err := parseISOTime(s, "15:04:05Z07:00", "15:04:05")
+		return TimeOfDay{Time: t}, err
+	case "decimal":
+		return Decimal(s), nil
+	case "uuid":
+		return uuid.Parse(s)
+	case "bytes":
+		return []byte(s), nil
+	}
+	return base64.StdEncoding.DecodeString(s)
+}
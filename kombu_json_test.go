@@ -0,0 +1,177 @@
+// Copyright (c) 2019 Sick Yoon
+// This file is part of gocelery which is released under MIT license.
+// See file LICENSE for full license details.
+
+package gocelery
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TestKombuDecodePython tests decoding of task message body encoded by kombu
+func TestKombuDecodePython(t *testing.T) {
+	body := `[[{"__type__": "datetime", "__value__": "2024-05-06T07:08:09.123456+00:00"},
+		{"__type__": "datetime", "__value__": "2024-05-06T07:08:09"},
+		{"__type__": "date", "__value__": "2024-05-06"},
+		{"__type__": "time", "__value__": "07:08:09.500000"},
+		{"__type__": "decimal", "__value__": "3.14159265358979323846"},
+		{"__type__": "uuid", "__value__": {"hex": "4f1b0f7e3c1a4d2b9e8f7a6b5c4d3e2f"}},
+		{"__type__": "bytes", "__value__": "hello"},
+		{"__type__": "base64", "__value__": "/wA="}],
+		{"nested": {"at": {"__type__": "date", "__value__": "2024-05-07"}},
+		 "unknown": {"__type__": "set", "__value__": [1, 2]}},
+		{"callbacks": null, "errbacks": null, "chain": null, "chord": null}]`
+	message, err := DecodeTaskMessageV2(base64.StdEncoding.EncodeToString([]byte(body)))
+	if err != nil {
+		t.Fatalf("failed to decode task message: %v", err)
+	}
+	defer releaseTaskMessageV2(message)
+
+	expected := []interface{}{
+		time.Date(2024, 5, 6, 7, 8, 9, 123456000, time.UTC),
+		time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC),
+		NewDate(2024, time.May, 6),
+		NewTimeOfDay(7, 8, 9, 500000000, time.UTC),
+		Decimal("3.14159265358979323846"),
+		uuid.MustParse("4f1b0f7e-3c1a-4d2b-9e8f-7a6b5c4d3e2f"),
+		[]byte("hello"),
+		[]byte{0xff, 0x00},
+	}
+	if len(message.Args) != len(expected) {
+		t.Fatalf("expected %d args, got %d", len(expected), len(message.Args))
+	}
+	for i, arg := range message.Args {
+		if at, ok := arg.(time.Time); ok {
+			if !at.Equal(expected[i].(time.Time)) {
+				t.Errorf("arg %d: expected %v, got %v", i, expected[i], arg)
+			}
+			continue
+		}
+		if !reflect.DeepEqual(arg, expected[i]) {
+			t.Errorf("arg %d: expected %#v, got %#v", i, expected[i], arg)
+		}
+	}
+	nested := message.Kwargs["nested"].(map[string]interface{})
+	if nested["at"] != NewDate(2024, time.May, 7) {
+		t.Errorf("expected nested date, got %#v", nested["at"])
+	}
+	if _, ok := message.Kwargs["unknown"].(map[string]interface{}); !ok {
+		t.Errorf("expected unknown marker to be kept, got %#v", message.Kwargs["unknown"])
+	}
+
+	invalid := `[[{"__type__": "datetime", "__value__": "yesterday"}], {}, {}]`
+	if _, err := DecodeTaskMessageV2(base64.StdEncoding.EncodeToString([]byte(invalid))); err == nil {
+		t.Error("expected error for invalid datetime")
+	}
+}
+
+// TestKombuRoundTrip tests that go values survive encoding and decoding of task message
+func TestKombuRoundTrip(t *testing.T) {
+	type event struct {
+		ID   uuid.UUID `json:"id"`
+		At   DateTime  `json:"at"`
+		Note string    `json:"note,omitempty"`
+	}
+	id := uuid.New()
+	at := time.Date(2024, 5, 6, 7, 8, 9, 123456000, time.FixedZone("", 2*60*60))
+	message := getTaskMessageV2(at, id, []byte("text"), []byte{0xff, 0xfe}, Decimal("0.10"), NewDate(2024, time.May, 6))
+	message.Kwargs["event"] = &event{ID: id, At: DateTime{Time: at}}
+	encoded, err := message.Encode()
+	releaseTaskMessageV2(message)
+	if err != nil {
+		t.Fatalf("failed to encode task message: %v", err)
+	}
+
+	body, _ := base64.StdEncoding.DecodeString(encoded)
+	var payload []interface{}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		t.Fatalf("failed to decode json: %v", err)
+	}
+	args := payload[0].([]interface{})
+	if !reflect.DeepEqual(args[0], map[string]interface{}{"__type__": "datetime", "__value__": "2024-05-06T07:08:09.123456+02:00"}) {
+		t.Errorf("unexpected datetime encoding %v", args[0])
+	}
+	if !reflect.DeepEqual(args[3], map[string]interface{}{"__type__": "base64", "__value__": "//4="}) {
+		t.Errorf("unexpected bytes encoding %v", args[3])
+	}
+
+	decoded, err := DecodeTaskMessageV2(encoded)
+	if err != nil {
+		t.Fatalf("failed to decode task message: %v", err)
+	}
+	defer releaseTaskMessageV2(decoded)
+	if !decoded.Args[0].(time.Time).Equal(at) || decoded.Args[1] != id ||
+		string(decoded.Args[2].([]byte)) != "text" || !reflect.DeepEqual(decoded.Args[3], []byte{0xff, 0xfe}) ||
+		decoded.Args[4] != Decimal("0.10") || decoded.Args[5] != NewDate(2024, time.May, 6) {
+		t.Errorf("unexpected decoded args %#v", decoded.Args)
+	}
+	// struct is encoded by encoding/json, so only its DateTime field keeps marker
+	decodedEvent := decoded.Kwargs["event"].(map[string]interface{})
+	if decodedEvent["id"] != id.String() || !decodedEvent["at"].(time.Time).Equal(at) {
+		t.Errorf("unexpected decoded struct %#v", decodedEvent)
+	}
+	if _, ok := decodedEvent["note"]; ok {
+		t.Error("expected empty field to be omitted")
+	}
+}
+
+// TestKombuResultMessage tests that results are encoded with kombu type markers
+func TestKombuResultMessage(t *testing.T) {
+	at := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+	resBytes, err := json.Marshal(&ResultMessage{ID: "id", Status: "SUCCESS", Result: at})
+	if err != nil {
+		t.Fatalf("failed to encode result: %v", err)
+	}
+	var raw map[string]interface{}
+	json.Unmarshal(resBytes, &raw)
+	if !reflect.DeepEqual(raw["result"], map[string]interface{}{"__type__": "datetime", "__value__": "2024-05-06T07:08:09.000000+00:00"}) {
+		t.Errorf("unexpected result encoding %s", resBytes)
+	}
+
+	var result ResultMessage
+	if err := json.Unmarshal(resBytes, &result); err != nil {
+		t.Fatalf("failed to decode result: %v", err)
+	}
+	var out time.Time
+	if err := decodeResult(result.ID, result.Result, &out); err != nil || !out.Equal(at) {
+		t.Errorf("expected %v, got %v: %v", at, out, err)
+	}
+}
+
+// money marshals itself through pointer receiver
+type money struct {
+	cents int64
+}
+
+func (m *money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(m.cents)
+}
+
+// TestKombuStructResult tests that struct results are encoded exactly like encoding/json does
+func TestKombuStructResult(t *testing.T) {
+	type A struct{ X int }
+	type B struct{ X int }
+	type invoice struct {
+		A
+		B
+		Count int64 `json:"count,string"`
+		Price money `json:"price"`
+	}
+	result := &invoice{A: A{X: 1}, B: B{X: 2}, Count: 5, Price: money{cents: 250}}
+	expected, _ := json.Marshal(result)
+	resBytes, err := json.Marshal(&ResultMessage{Status: "SUCCESS", Result: result})
+	if err != nil {
+		t.Fatalf("failed to encode result: %v", err)
+	}
+	var raw map[string]json.RawMessage
+	json.Unmarshal(resBytes, &raw)
+	if string(raw["result"]) != string(expected) {
+		t.Errorf("expected result %s, got %s", expected, raw["result"])
+	}
+}
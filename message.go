@@ -189,7 +189,33 @@ type ResultMessage struct {
 	Children  []interface{} `json:"children"`
 }
 
+// MarshalJSON encodes result with kombu type markers like celery does
+func (rm ResultMessage) MarshalJSON() ([]byte, error) {
+	type resultMessage ResultMessage
+	msg := resultMessage(rm)
+	msg.Result = kombuEncode(rm.Result)
+	return json.Marshal(msg)
+}
+
+// UnmarshalJSON decodes result replacing kombu type markers with go values
+func (rm *ResultMessage) UnmarshalJSON(data []byte) error {
+	type resultMessage ResultMessage
+	var msg resultMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return err
+	}
+	result, err := kombuDecode(msg.Result)
+	if err != nil {
+		return err
+	}
+	msg.Result = result
+	*rm = ResultMessage(msg)
+	return nil
+}
+
 func (rm *ResultMessage) reset() {
+	rm.Status = "SUCCESS"
+	rm.Traceback = nil
 	rm.Result = nil
 }
 
@@ -215,6 +241,36 @@ func getReflectionResultMessage(val *reflect.Value) *ResultMessage {
 	return msg
 }
 
+// getFailureResultMessage returns FAILURE result holding err
+// in the form celery stores exceptions
+func getFailureResultMessage(err error) *ResultMessage {
+	return getExceptionResultMessage("Exception", err)
+}
+
+// getExceptionResultMessage returns FAILURE result reporting err as python builtin exception excType
+func getExceptionResultMessage(excType string, err error) *ResultMessage {
+	msg := resultMessagePool.Get().(*ResultMessage)
+	msg.Status = "FAILURE"
+	msg.Result = map[string]interface{}{
+		"exc_type":    excType,
+		"exc_module":  "builtins",
+		"exc_message": []interface{}{err.Error()},
+	}
+	return msg
+}
+
+// getRevokedResultMessage returns REVOKED result reporting celery TaskRevokedError with reason
+func getRevokedResultMessage(reason string) *ResultMessage {
+	msg := resultMessagePool.Get().(*ResultMessage)
+	msg.Status = "REVOKED"
+	msg.Result = map[string]interface{}{
+		"exc_type":    "TaskRevokedError",
+		"exc_module":  "celery.exceptions",
+		"exc_message": []interface{}{reason},
+	}
+	return msg
+}
+
 func releaseResultMessage(v *ResultMessage) {
 	v.reset()
 	resultMessagePool.Put(v)
@@ -1,6 +1,7 @@
 package gocelery
 
 import (
+	"bytes"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
@@ -83,6 +84,11 @@ type CeleryDeliveryInfoV2 struct {
 
 // GetTaskMessageV2 retrieve and decode task messages from broker
 func (cm *CeleryMessageV2) GetTaskMessageV2() *TaskMessageV2 {
+	return cm.getTaskMessageV2(false)
+}
+
+// getTaskMessageV2 decodes task message keeping numbers as json.Number when useNumber is set
+func (cm *CeleryMessageV2) getTaskMessageV2(useNumber bool) *TaskMessageV2 {
 	// ensure content-type is 'application/json'
 	if cm.ContentType != "application/json" {
 		log.Println("unsupported content type " + cm.ContentType)
@@ -99,7 +105,7 @@ func (cm *CeleryMessageV2) GetTaskMessageV2() *TaskMessageV2 {
 		return nil
 	}
 	// decode body
-	taskMessage, err := DecodeTaskMessageV2(cm.Body)
+	taskMessage, err := decodeTaskMessageV2(cm.Body, useNumber)
 	if err != nil {
 		log.Println("failed to decode task message")
 		return nil
@@ -236,6 +242,10 @@ func getTaskMessageV2(args ...interface{}) *TaskMessageV2 {
 func getTaskMessageV2WithKwargs(args []interface{}, kwargs map[string]interface{}) *TaskMessageV2 {
 	msg := taskMessagePoolV2.Get().(*TaskMessageV2)
 	msg.Args = append(msg.Args[:0], args...)
+	if msg.Args == nil {
+		// celery expects args list even when task takes keyword arguments only
+		msg.Args = []interface{}{}
+	}
 	if kwargs == nil {
 		for k := range msg.Kwargs {
 			delete(msg.Kwargs, k)
@@ -259,6 +269,16 @@ func releaseTaskMessageV2(v *TaskMessageV2) {
 
 // DecodeTaskMessageV2 decodes base64 encrypted body and return TaskMessage object
 func DecodeTaskMessageV2(encodedBody string) (*TaskMessageV2, error) {
+	return decodeTaskMessageV2(encodedBody, false)
+}
+
+// DecodeTaskMessageV2UseNumber decodes task message keeping numeric arguments as json.Number,
+// so that integers above 2^53 do not lose precision
+func DecodeTaskMessageV2UseNumber(encodedBody string) (*TaskMessageV2, error) {
+	return decodeTaskMessageV2(encodedBody, true)
+}
+
+func decodeTaskMessageV2(encodedBody string, useNumber bool) (*TaskMessageV2, error) {
 	body, err := base64.StdEncoding.DecodeString(encodedBody)
 	if err != nil {
 		return nil, err
@@ -275,14 +295,22 @@ func DecodeTaskMessageV2(encodedBody string) (*TaskMessageV2, error) {
 	}
 
 	var args []interface{}
-	if err := json.Unmarshal(payload[0], &args); err != nil {
+	if err := unmarshalArguments(payload[0], &args, useNumber); err != nil {
+		releaseTaskMessageV2(message)
+		return nil, err
+	}
+	if _, err := kombuDecode(args); err != nil {
 		releaseTaskMessageV2(message)
 		return nil, err
 	}
 	message.Args = append(message.Args[:0], args...)
 
 	var kwargs map[string]interface{}
-	if err := json.Unmarshal(payload[1], &kwargs); err != nil {
+	if err := unmarshalArguments(payload[1], &kwargs, useNumber); err != nil {
+		releaseTaskMessageV2(message)
+		return nil, err
+	}
+	if _, err := kombuDecode(kwargs); err != nil {
 		releaseTaskMessageV2(message)
 		return nil, err
 	}
@@ -301,12 +329,22 @@ func DecodeTaskMessageV2(encodedBody string) (*TaskMessageV2, error) {
 	return message, nil
 }
 
+// unmarshalArguments decodes task arguments, numbers are decoded as json.Number when useNumber is set
+func unmarshalArguments(data []byte, out interface{}, useNumber bool) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if useNumber {
+		decoder.UseNumber()
+	}
+	return decoder.Decode(out)
+}
+
 // Encode returns base64 json encoded string
 func (tm *TaskMessageV2) Encode() (string, error) {
 	if tm.Args == nil {
 		tm.Args = make([]interface{}, 0)
 	}
-	messageArr := [3]interface{}{tm.Args, tm.Kwargs, tm.Embed}
+	// datetime, uuid and bytes arguments are sent with kombu type markers
+	messageArr := [3]interface{}{kombuEncode(tm.Args), kombuEncode(tm.Kwargs), tm.Embed}
 	jsonData, err := json.Marshal(messageArr)
 	if err != nil {
 		return "", err
@@ -0,0 +1,120 @@
+// Copyright (c) 2019 Sick Yoon
+// This file is part of gocelery which is released under MIT license.
+// See file LICENSE for full license details.
+
+package gocelery
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+// snowflakeBody holds integers which cannot be represented by float64
+const snowflakeBody = `[[1234567890123456789, 18446744073709551615], {"order_id": 1234567890123456789, "total": "x"}, {}]`
+
+// TestDecodeUseNumber tests that numeric arguments are decoded as json.Number
+func TestDecodeUseNumber(t *testing.T) {
+	encoded := base64.StdEncoding.EncodeToString([]byte(snowflakeBody))
+	message, err := DecodeTaskMessageV2UseNumber(encoded)
+	if err != nil {
+		t.Fatalf("failed to decode task message: %v", err)
+	}
+	defer releaseTaskMessageV2(message)
+	if message.Args[0] != json.Number("1234567890123456789") || message.Kwargs["order_id"] != json.Number("1234567890123456789") {
+		t.Errorf("expected json.Number arguments, got %#v %#v", message.Args, message.Kwargs)
+	}
+
+	floatMessage, err := DecodeTaskMessageV2(encoded)
+	if err != nil {
+		t.Fatalf("failed to decode task message: %v", err)
+	}
+	defer releaseTaskMessageV2(floatMessage)
+	if _, ok := floatMessage.Args[0].(float64); !ok {
+		t.Errorf("expected float64 argument by default, got %T", floatMessage.Args[0])
+	}
+}
+
+// orderParams is params struct of task taking snowflake id
+type orderParams struct {
+	OrderID int64    `celery:"order_id,required"`
+	Total   *big.Int `celery:"total"`
+}
+
+// TestUseNumberConversion tests that json.Number is converted exactly on every task path
+func TestUseNumberConversion(t *testing.T) {
+	celeryWorker := NewCeleryWorker(&stubBroker{}, &stubBackend{}, 1)
+	celeryWorker.Register("reflect", func(id int64, max uint64) bool {
+		return id == 1234567890123456789 && max == 18446744073709551615
+	})
+	celeryWorker.Register("params", func(params orderParams) string {
+		return big.NewInt(params.OrderID).String() + "/" + params.Total.String()
+	})
+	RegisterFunc(celeryWorker, "typed", func(args struct {
+		OrderID int64 `json:"order_id"`
+	}) (int64, error) {
+		return args.OrderID, nil
+	})
+
+	testCases := []struct {
+		name     string
+		taskName string
+		args     []interface{}
+		kwargs   map[string]interface{}
+		expected interface{}
+	}{
+		{
+			name:     "reflection",
+			taskName: "reflect",
+			args:     []interface{}{json.Number("1234567890123456789"), json.Number("18446744073709551615")},
+			expected: true,
+		},
+		{
+			name:     "params struct",
+			taskName: "params",
+			args:     []interface{}{},
+			kwargs:   map[string]interface{}{"order_id": json.Number("1234567890123456789"), "total": json.Number("123456789012345678901234567890")},
+			expected: "1234567890123456789/123456789012345678901234567890",
+		},
+		{
+			name:     "typed",
+			taskName: "typed",
+			args:     []interface{}{json.Number("1234567890123456789")},
+			expected: int64(1234567890123456789),
+		},
+	}
+	for _, tc := range testCases {
+		message := getTaskMessageV2WithKwargs(tc.args, tc.kwargs)
+		res, err := celeryWorker.RunTaskV2(tc.taskName, "id", message)
+		releaseTaskMessageV2(message)
+		if err != nil || res.Result != tc.expected {
+			t.Errorf("test '%s': expected %v, got %v: %v", tc.name, tc.expected, res, err)
+		}
+	}
+
+	for _, args := range [][]interface{}{
+		{json.Number("9223372036854775808"), json.Number("1")},
+		{json.Number("1.5"), json.Number("1")},
+		{json.Number("1"), json.Number("-1")},
+		{json.Number("1"), json.Number("18446744073709551616")},
+		{json.Number("1"), json.Number("-0")},
+	} {
+		message := getTaskMessageV2(args...)
+		res, err := celeryWorker.RunTaskV2("reflect", "id", message)
+		releaseTaskMessageV2(message)
+		if !isTypeErrorResult(res, err) {
+			t.Errorf("expected TypeError failure for %v, got %v %v", args, res, err)
+		}
+	}
+	for _, number := range []json.Number{"-1", "-0"} {
+		message := getTaskMessageV2(json.Number("1"), number)
+		res, err := celeryWorker.RunTaskV2("reflect", "id", message)
+		releaseTaskMessageV2(message)
+		if !isTypeErrorResult(res, err) || !strings.Contains(fmt.Sprint(res.Result), "negative") {
+			t.Errorf("expected %s to be reported as negative, got %v %v", number, res, err)
+		}
+	}
+}
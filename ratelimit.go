@@ -0,0 +1,33 @@
+// Copyright (c) 2019 Sick Yoon
+// This file is part of gocelery which is released under MIT license.
+// See file LICENSE for full license details.
+
+package gocelery
+
+import (
+	"fmt"
+	"time"
+)
+
+// RateLimit declares a quota shared by every worker executing a task.
+// Tasks using the same Key share a single quota across the cluster.
+type RateLimit struct {
+	Key    string        // shared limit key, defaults to task name when empty
+	Limit  int           // number of executions allowed within Period
+	Period time.Duration // length of the sliding window
+}
+
+// RateLimiter is interface for distributed rate limiter
+type RateLimiter interface {
+	// Allow reports whether one more execution fits in the quota of key.
+	// When it does not, it returns how long to wait before trying again.
+	Allow(key string, limit int, period time.Duration) (bool, time.Duration, error)
+}
+
+// validate reports limit which would never let task run or never throttle it
+func (rl *RateLimit) validate() error {
+	if rl.Limit <= 0 || rl.Period < time.Millisecond {
+		return fmt.Errorf("invalid rate limit %d per %v: limit must be positive and period at least 1ms", rl.Limit, rl.Period)
+	}
+	return nil
+}
@@ -0,0 +1,219 @@
+// Copyright (c) 2019 Sick Yoon
+// This file is part of gocelery which is released under MIT license.
+// See file LICENSE for full license details.
+
+package gocelery
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// stubBroker records sent messages in memory
+type stubBroker struct {
+	sync.Mutex
+	sent   []*CeleryMessage
+	sentV2 []*CeleryMessageV2
+}
+
+func (b *stubBroker) SendCeleryMessage(message *CeleryMessage) error {
+	b.Lock()
+	defer b.Unlock()
+	copied := *message
+	b.sent = append(b.sent, &copied)
+	return nil
+}
+
+func (b *stubBroker) GetTaskMessage() (*TaskMessage, error) {
+	return nil, fmt.Errorf("stub broker is empty")
+}
+
+func (b *stubBroker) SendCeleryMessageV2(message *CeleryMessageV2) error {
+	b.Lock()
+	defer b.Unlock()
+	copied := *message
+	b.sentV2 = append(b.sentV2, &copied)
+	return nil
+}
+
+func (b *stubBroker) GetCeleryMessageV2() (*CeleryMessageV2, error) {
+	return nil, fmt.Errorf("stub broker is empty")
+}
+
+// stubBackend records results in memory
+type stubBackend struct {
+	sync.Map
+}
+
+func (b *stubBackend) GetResult(taskID string) (*ResultMessage, error) {
+	val, ok := b.Load(taskID)
+	if !ok {
+		return nil, fmt.Errorf("result not available")
+	}
+	return val.(*ResultMessage), nil
+}
+
+func (b *stubBackend) SetResult(taskID string, result *ResultMessage) error {
+	copied := *result
+	b.Store(taskID, &copied)
+	return nil
+}
+
+// denyLimiter rejects every execution
+type denyLimiter struct {
+	wait time.Duration
+	keys []string
+}
+
+func (l *denyLimiter) Allow(key string, limit int, period time.Duration) (bool, time.Duration, error) {
+	l.keys = append(l.keys, key)
+	return false, l.wait, nil
+}
+
+// TestRateLimitRequeue tests that a task over quota is sent back with a countdown
+func TestRateLimitRequeue(t *testing.T) {
+	broker := &stubBroker{}
+	backend := &stubBackend{}
+	limiter := &denyLimiter{wait: time.Minute}
+	worker := NewCeleryWorker(broker, backend, 1)
+	worker.Register("add", add)
+	worker.SetRateLimiter(limiter)
+	if err := worker.SetRateLimit("add", &RateLimit{Key: "partner-api", Limit: 10, Period: time.Second}); err != nil {
+		t.Fatalf("failed to set rate limit: %v", err)
+	}
+
+	taskMessage := getTaskMessageV2(1, 2)
+	encoded, err := taskMessage.Encode()
+	if err != nil {
+		t.Fatalf("failed to encode task message: %v", err)
+	}
+	headers := buildCeleryHeadersV2("add", taskMessage.Args, nil)
+	celeryMessage := getCeleryMessageV2(encoded, *headers)
+
+	before := time.Now()
+	worker.handleMessageV2(context.Background(), celeryMessage, taskMessage, nil)
+
+	if len(limiter.keys) != 1 || limiter.keys[0] != "partner-api" {
+		t.Errorf("expected limiter to be asked for shared key, got %v", limiter.keys)
+	}
+	if _, err := backend.GetResult(headers.ID); err == nil {
+		t.Error("rate limited task should not have been executed")
+	}
+	if len(broker.sentV2) != 1 {
+		t.Fatalf("expected rate limited task to be requeued once, got %d", len(broker.sentV2))
+	}
+	eta, ok := parseETA(broker.sentV2[0].Headers.Eta)
+	if !ok {
+		t.Fatalf("requeued task has invalid eta %v", broker.sentV2[0].Headers.Eta)
+	}
+	if eta.Before(before.Add(limiter.wait - time.Second)) {
+		t.Errorf("requeued task eta %v is earlier than countdown", eta)
+	}
+}
+
+// TestRateLimitAllow tests that a task without limit runs immediately
+func TestRateLimitAllow(t *testing.T) {
+	broker := &stubBroker{}
+	backend := &stubBackend{}
+	worker := NewCeleryWorker(broker, backend, 1)
+	worker.Register("add", add)
+	worker.SetRateLimiter(&denyLimiter{})
+
+	taskMessage := getTaskMessageV2(1, 2)
+	encoded, _ := taskMessage.Encode()
+	headers := buildCeleryHeadersV2("add", taskMessage.Args, nil)
+	celeryMessage := getCeleryMessageV2(encoded, *headers)
+	worker.handleMessageV2(context.Background(), celeryMessage, taskMessage, nil)
+
+	if len(broker.sentV2) != 0 {
+		t.Errorf("unlimited task should not be requeued")
+	}
+	res, err := backend.GetResult(headers.ID)
+	if err != nil {
+		t.Fatalf("expected result to be stored: %v", err)
+	}
+	if res.Result != int64(3) {
+		t.Errorf("expected result 3, got %v", res.Result)
+	}
+}
+
+// TestRateLimitInvalid tests that limits which never let task run or have no window are rejected
+func TestRateLimitInvalid(t *testing.T) {
+	worker := NewCeleryWorker(&stubBroker{}, &stubBackend{}, 1)
+	for _, limit := range []*RateLimit{
+		{Limit: 0, Period: time.Second},
+		{Limit: -1, Period: time.Second},
+		{Limit: 10, Period: 0},
+		{Limit: 10, Period: time.Microsecond},
+	} {
+		if err := worker.SetRateLimit("add", limit); err == nil {
+			t.Errorf("expected rate limit %d per %v to be rejected", limit.Limit, limit.Period)
+		}
+	}
+	if _, ok := worker.rateLimits["add"]; ok {
+		t.Error("invalid rate limit should not be declared")
+	}
+	if err := worker.SetRateLimit("add", nil); err != nil {
+		t.Errorf("failed to remove rate limit: %v", err)
+	}
+}
+
+// TestRedisRateLimiter is Redis specific test of sliding window script
+func TestRedisRateLimiter(t *testing.T) {
+	limiter := NewRedisRateLimiter(redisPool)
+	key := uuid.New().String()
+	period := 500 * time.Millisecond
+	for i := 0; i < 2; i++ {
+		allowed, _, err := limiter.Allow(key, 2, period)
+		if err != nil {
+			t.Fatalf("failed to check rate limit: %v", err)
+		}
+		if !allowed {
+			t.Fatalf("execution %d should be within quota", i+1)
+		}
+	}
+	allowed, wait, err := limiter.Allow(key, 2, period)
+	if err != nil {
+		t.Fatalf("failed to check rate limit: %v", err)
+	}
+	if allowed {
+		t.Fatal("execution over quota should not be allowed")
+	}
+	if wait <= 0 || wait > period {
+		t.Errorf("expected wait within window, got %v", wait)
+	}
+	time.Sleep(wait + 10*time.Millisecond)
+	if allowed, _, err := limiter.Allow(key, 2, period); err != nil || !allowed {
+		t.Errorf("execution after window slid should be allowed: %v", err)
+	}
+	if _, _, err := limiter.Allow(key, 2, 0); err == nil {
+		t.Error("expected zero period to be rejected")
+	}
+}
+
+// TestParseETA tests eta formats produced by python and go clients
+func TestParseETA(t *testing.T) {
+	for _, eta := range []string{
+		"2026-01-02T03:04:05.123456+00:00",
+		"2026-01-02T03:04:05Z",
+		"2026-01-02T03:04:05.123456",
+		formatETA(time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)),
+	} {
+		parsed, ok := parseETA(eta)
+		if !ok {
+			t.Errorf("failed to parse eta %s", eta)
+			continue
+		}
+		if parsed.UTC().Hour() != 3 || parsed.UTC().Second() != 5 {
+			t.Errorf("eta %s parsed as %v", eta, parsed)
+		}
+	}
+	if _, ok := parseETA(nil); ok {
+		t.Error("nil eta should not be parsed")
+	}
+}
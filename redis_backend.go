@@ -7,6 +7,9 @@ package gocelery
 import (
 	"encoding/json"
 	"fmt"
+	"log"
+	"sync"
+	"time"
 
 	"github.com/gomodule/redigo/redis"
 )
@@ -14,6 +17,9 @@ import (
 // RedisCeleryBackend is celery backend for redis
 type RedisCeleryBackend struct {
 	*redis.Pool
+
+	subscriberOnce sync.Once
+	subscriber     *redisResultSubscriber
 }
 
 // NewRedisBackend creates new RedisCeleryBackend with given redis pool.
@@ -59,8 +65,148 @@ func (cb *RedisCeleryBackend) SetResult(taskID string, result *ResultMessage) er
 	if err != nil {
 		return err
 	}
+	key := fmt.Sprintf("celery-task-meta-%s", taskID)
 	conn := cb.Get()
 	defer conn.Close()
-	_, err = conn.Do("SETEX", fmt.Sprintf("celery-task-meta-%s", taskID), 86400, resBytes)
+	// result is published on channel named after its key like celery does
+	conn.Send("MULTI")
+	conn.Send("SETEX", key, 86400, resBytes)
+	conn.Send("PUBLISH", key, resBytes)
+	_, err = conn.Do("EXEC")
 	return err
 }
+
+// WaitResult returns channel signalled when result of task is published.
+// Returned cancel func must be called once waiting is over.
+func (cb *RedisCeleryBackend) WaitResult(taskID string) (<-chan struct{}, func(), error) {
+	cb.subscriberOnce.Do(func() {
+		cb.subscriber = &redisResultSubscriber{
+			pool:    cb.Pool,
+			waiters: map[string]map[chan struct{}]bool{},
+		}
+	})
+	return cb.subscriber.wait(fmt.Sprintf("celery-task-meta-%s", taskID))
+}
+
+// redisResultSubscriber shares one pub/sub connection among all waiting results
+type redisResultSubscriber struct {
+	pool *redis.Pool
+
+	lock sync.Mutex
+	conn *redis.PubSubConn
+	// receiving is set while receive runs, conn is nil while it replaces lost connection
+	receiving bool
+	waiters   map[string]map[chan struct{}]bool
+}
+
+const (
+	redisResubscribeMinBackoff = 100 * time.Millisecond
+	redisResubscribeMaxBackoff = 30 * time.Second
+)
+
+// wait subscribes to channel and registers waiter woken by its messages
+func (s *redisResultSubscriber) wait(channel string) (<-chan struct{}, func(), error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if !s.receiving {
+		conn := &redis.PubSubConn{Conn: s.pool.Get()}
+		if err := conn.Subscribe(channel); err != nil {
+			conn.Close()
+			return nil, nil, err
+		}
+		s.conn = conn
+		s.receiving = true
+		s.waiters[channel] = map[chan struct{}]bool{}
+		go s.receive(conn)
+	} else if len(s.waiters[channel]) == 0 {
+		// channel failed to subscribe is subscribed again by receive with new connection
+		if s.conn != nil {
+			s.conn.Subscribe(channel)
+		}
+		s.waiters[channel] = map[chan struct{}]bool{}
+	}
+	notify := make(chan struct{}, 1)
+	s.waiters[channel][notify] = true
+	cancel := func() {
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		delete(s.waiters[channel], notify)
+		if len(s.waiters[channel]) == 0 {
+			delete(s.waiters, channel)
+			if s.conn != nil {
+				s.conn.Unsubscribe(channel)
+			}
+		}
+	}
+	return notify, cancel, nil
+}
+
+// receive wakes waiters of published results. Lost connection is replaced with backoff
+// and channels of all current waiters are subscribed again, until nobody waits.
+func (s *redisResultSubscriber) receive(conn *redis.PubSubConn) {
+	for {
+		switch v := conn.ReceiveWithTimeout(0).(type) {
+		case redis.Message:
+			s.lock.Lock()
+			s.notify(v.Channel)
+			s.lock.Unlock()
+		case error:
+			log.Printf("result subscription closed: %+v", v)
+			s.lock.Lock()
+			s.conn = nil
+			s.lock.Unlock()
+			conn.Close()
+			if conn = s.resubscribe(); conn == nil {
+				return
+			}
+		}
+	}
+}
+
+// resubscribe opens new connection subscribed to channels of all waiters.
+// Waiters are woken once it succeeds so that they poll for results published meanwhile.
+// It returns nil and stops receiving when nobody waits.
+func (s *redisResultSubscriber) resubscribe() *redis.PubSubConn {
+	backoff := redisResubscribeMinBackoff
+	for {
+		conn := &redis.PubSubConn{Conn: s.pool.Get()}
+		s.lock.Lock()
+		if len(s.waiters) == 0 {
+			s.receiving = false
+			s.lock.Unlock()
+			conn.Close()
+			return nil
+		}
+		var err error
+		for channel := range s.waiters {
+			if err = conn.Subscribe(channel); err != nil {
+				break
+			}
+		}
+		if err == nil {
+			s.conn = conn
+			for channel := range s.waiters {
+				s.notify(channel)
+			}
+			s.lock.Unlock()
+			return conn
+		}
+		s.lock.Unlock()
+		conn.Close()
+		log.Printf("failed to resubscribe results, retrying in %v: %+v", backoff, err)
+		time.Sleep(backoff)
+		if backoff *= 2; backoff > redisResubscribeMaxBackoff {
+			backoff = redisResubscribeMaxBackoff
+		}
+	}
+}
+
+// notify wakes waiters of channel without blocking on those already woken
+func (s *redisResultSubscriber) notify(channel string) {
+	for waiter := range s.waiters[channel] {
+		select {
+		case waiter <- struct{}{}:
+		default:
+		}
+	}
+}
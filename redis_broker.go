@@ -5,11 +5,18 @@
 package gocelery
 
 import (
+	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gomodule/redigo/redis"
+	"github.com/google/uuid"
 )
 
 // RedisCeleryBroker is celery broker for redis
@@ -18,14 +25,53 @@ type RedisCeleryBroker struct {
 	QueueName string
 	// map[taskName]queueName
 	TaskQueue map[string]string
+	// Queues lists queues consumed by workers, QueueName is consumed when nil.
+	// Queues are tried in listed order unless RoundRobin is set,
+	// which rotates the order after each fetch so that no queue starves.
+	Queues     []string
+	RoundRobin bool
+	rotation   uint32
+	queueLock  sync.RWMutex
+	// ControlChannel is pub/sub channel of celery remote control broadcasts
+	ControlChannel string
+	// PrioritySteps emulate message priorities with one list per step,
+	// named like celery redis transport does. 0 is the highest priority.
+	PrioritySteps []int
+	// DelayedDelivery keeps protocol v2 messages with future ETA in sorted set
+	// of their queue scored by due time, instead of holding them in worker memory.
+	// Workers move due messages to the queue with MoveDelayed.
+	DelayedDelivery  bool
+	DelayedKeyPrefix string
+
+	// AcksLate keeps fetched messages in unacked store until they are acknowledged.
+	// Messages not acknowledged within VisibilityTimeout are restored to their queue.
+	AcksLate          bool
+	VisibilityTimeout time.Duration
+	UnackedKey        string
+	UnackedIndexKey   string
+	// DeadLetterKey is list messages which cannot be parsed are moved to
+	DeadLetterKey string
 }
 
+// DefaultPrioritySteps are priority steps used by celery redis transport
+var DefaultPrioritySteps = []int{0, 3, 6, 9}
+
+// prioritySeparator separates queue name from priority step in redis list name
+const prioritySeparator = "\x06\x16"
+
 // NewRedisBroker creates new RedisCeleryBroker with given redis connection pool
 func NewRedisBroker(conn *redis.Pool, taskQueue map[string]string) *RedisCeleryBroker {
 	return &RedisCeleryBroker{
-		Pool:      conn,
-		QueueName: "celery",
-		TaskQueue: taskQueue,
+		Pool:              conn,
+		QueueName:         "celery",
+		TaskQueue:         taskQueue,
+		ControlChannel:    "/0.celery.pidbox",
+		PrioritySteps:     DefaultPrioritySteps,
+		DelayedKeyPrefix:  "gocelery-delayed-",
+		VisibilityTimeout: time.Hour,
+		UnackedKey:        "unacked",
+		UnackedIndexKey:   "unacked_index",
+		DeadLetterKey:     "gocelery-dead-letter",
 	}
 }
 
@@ -35,8 +81,15 @@ func NewRedisBroker(conn *redis.Pool, taskQueue map[string]string) *RedisCeleryB
 // and should not be used. Use NewRedisBroker instead to create new RedisCeleryBroker.
 func NewRedisCeleryBroker(uri string) *RedisCeleryBroker {
 	return &RedisCeleryBroker{
-		Pool:      NewRedisPool(uri),
-		QueueName: "celery",
+		Pool:              NewRedisPool(uri),
+		QueueName:         "celery",
+		ControlChannel:    "/0.celery.pidbox",
+		PrioritySteps:     DefaultPrioritySteps,
+		DelayedKeyPrefix:  "gocelery-delayed-",
+		VisibilityTimeout: time.Hour,
+		UnackedKey:        "unacked",
+		UnackedIndexKey:   "unacked_index",
+		DeadLetterKey:     "gocelery-dead-letter",
 	}
 }
 
@@ -48,7 +101,8 @@ func (cb *RedisCeleryBroker) SendCeleryMessage(message *CeleryMessage) error {
 	}
 	conn := cb.Get()
 	defer conn.Close()
-	_, err = conn.Do("LPUSH", cb.QueueName, jsonBytes)
+	queueName := cb.priorityQueue(cb.QueueName, message.Properties.DeliveryInfo.Priority)
+	_, err = conn.Do("LPUSH", queueName, jsonBytes)
 	if err != nil {
 		return err
 	}
@@ -57,21 +111,12 @@ func (cb *RedisCeleryBroker) SendCeleryMessage(message *CeleryMessage) error {
 
 // GetCeleryMessage retrieves celery message from redis queue
 func (cb *RedisCeleryBroker) GetCeleryMessage() (*CeleryMessage, error) {
-	conn := cb.Get()
-	defer conn.Close()
-	messageJSON, err := conn.Do("BRPOP", cb.QueueName, "1")
+	_, messageBytes, err := cb.pop()
 	if err != nil {
 		return nil, err
 	}
-	if messageJSON == nil {
-		return nil, fmt.Errorf("null message received from redis")
-	}
-	messageList := messageJSON.([]interface{})
-	if string(messageList[0].([]byte)) != cb.QueueName {
-		return nil, fmt.Errorf("not a celery message: %v", messageList[0])
-	}
 	var message CeleryMessage
-	if err := json.Unmarshal(messageList[1].([]byte), &message); err != nil {
+	if err := json.Unmarshal(messageBytes, &message); err != nil {
 		return nil, err
 	}
 	return &message, nil
@@ -106,6 +151,11 @@ func (cb *RedisCeleryBroker) SendCeleryMessageV2(message *CeleryMessageV2) error
 	// if message.Queue != "" {
 	// 	queueName = message.Queue
 	// }
+	queueName = cb.priorityQueue(queueName, message.Properties.Priority)
+	if eta, ok := parseETA(message.Headers.Eta); ok && cb.DelayedDelivery && eta.After(time.Now()) {
+		_, err = conn.Do("ZADD", cb.DelayedKeyPrefix+queueName, float64(eta.UnixNano())/1e9, jsonBytes)
+		return err
+	}
 	_, err = conn.Do("LPUSH", queueName, jsonBytes)
 	if err != nil {
 		return err
@@ -115,27 +165,526 @@ func (cb *RedisCeleryBroker) SendCeleryMessageV2(message *CeleryMessageV2) error
 
 // GetCeleryMessageV2 retrieves celery message from redis queue
 func (cb *RedisCeleryBroker) GetCeleryMessageV2() (*CeleryMessageV2, error) {
+	_, messageBytes, err := cb.pop()
+	if err != nil {
+		return nil, err
+	}
+	var message CeleryMessageV2
+	if err := json.Unmarshal(messageBytes, &message); err != nil {
+		return nil, err
+	}
+	return &message, nil
+}
+
+// GetCeleryEnvelope retrieves message of either protocol version from redis queue.
+// With AcksLate message is kept in unacked store until its delivery is acknowledged.
+func (cb *RedisCeleryBroker) GetCeleryEnvelope() (*CeleryEnvelope, error) {
+	var messageBytes []byte
+	var delivery Delivery
+	var err error
+	if cb.AcksLate {
+		messageBytes, delivery, err = cb.popUnacked()
+	} else {
+		_, messageBytes, err = cb.pop()
+	}
+	if err != nil {
+		return nil, err
+	}
+	var envelope CeleryEnvelope
+	if err := json.Unmarshal(messageBytes, &envelope); err != nil {
+		// message which cannot be parsed is kept for inspection instead of being dropped
+		tag := ""
+		if unacked, ok := delivery.(*redisDelivery); ok {
+			tag = unacked.tag
+		}
+		if dlErr := cb.deadLetter(tag, messageBytes); dlErr != nil {
+			log.Printf("failed to move invalid message to %s: %+v", cb.DeadLetterKey, dlErr)
+		}
+		return nil, err
+	}
+	envelope.Delivery = delivery
+	return &envelope, nil
+}
+
+// consumedQueues returns queues in the order they should be tried
+func (cb *RedisCeleryBroker) consumedQueues() []string {
+	cb.queueLock.RLock()
+	defer cb.queueLock.RUnlock()
+	if cb.Queues == nil {
+		return []string{cb.QueueName}
+	}
+	if !cb.RoundRobin || len(cb.Queues) == 1 {
+		return cb.Queues
+	}
+	offset := int(atomic.AddUint32(&cb.rotation, 1) % uint32(len(cb.Queues)))
+	queues := make([]string, 0, len(cb.Queues))
+	queues = append(queues, cb.Queues[offset:]...)
+	return append(queues, cb.Queues[:offset]...)
+}
+
+// priorityStep rounds priority down to the closest priority step
+func (cb *RedisCeleryBroker) priorityStep(priority int) int {
+	if priority < 0 {
+		priority = 0
+	} else if priority > 9 {
+		priority = 9
+	}
+	step := 0
+	for _, s := range cb.PrioritySteps {
+		if s <= priority && s > step {
+			step = s
+		}
+	}
+	return step
+}
+
+// priorityQueue returns name of redis list holding messages of queue with given priority
+func (cb *RedisCeleryBroker) priorityQueue(queueName string, priority int) string {
+	step := cb.priorityStep(priority)
+	if step == 0 {
+		return queueName
+	}
+	return fmt.Sprintf("%s%s%d", queueName, prioritySeparator, step)
+}
+
+// messagePriority reads priority of raw message of either protocol version
+func messagePriority(messageBytes []byte) int {
+	var message struct {
+		Properties struct {
+			Priority     *int `json:"priority"`
+			DeliveryInfo struct {
+				Priority int `json:"priority"`
+			} `json:"delivery_info"`
+		} `json:"properties"`
+	}
+	if err := json.Unmarshal(messageBytes, &message); err != nil {
+		return 0
+	}
+	if message.Properties.Priority != nil {
+		return *message.Properties.Priority
+	}
+	return message.Properties.DeliveryInfo.Priority
+}
+
+// popKeys returns redis lists of consumed queues in the order they should be
+// popped, higher priority steps of all queues first, with names of their queues
+func (cb *RedisCeleryBroker) popKeys() ([]string, []string, error) {
+	queues := cb.consumedQueues()
+	if len(queues) == 0 {
+		return nil, nil, fmt.Errorf("no queue is consumed")
+	}
+	steps := cb.PrioritySteps
+	if len(steps) == 0 {
+		steps = []int{0}
+	}
+	seen := make(map[string]bool, len(queues)*len(steps))
+	keys := make([]string, 0, len(queues)*len(steps))
+	names := make([]string, 0, len(queues)*len(steps))
+	for _, step := range steps {
+		for _, queueName := range queues {
+			key := cb.priorityQueue(queueName, step)
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+				names = append(names, queueName)
+			}
+		}
+	}
+	return keys, names, nil
+}
+
+// pop pops raw message from first non-empty consumed queue, trying
+// higher priority steps of all queues first, and returns name of the queue it came from
+func (cb *RedisCeleryBroker) pop() (string, []byte, error) {
+	keys, names, err := cb.popKeys()
+	if err != nil {
+		return "", nil, err
+	}
+	args := make([]interface{}, 0, len(keys)+1)
+	for _, key := range keys {
+		args = append(args, key)
+	}
+	args = append(args, "1")
 	conn := cb.Get()
 	defer conn.Close()
-	messageJSON, err := conn.Do("BRPOP", cb.QueueName, "1")
+	messageJSON, err := conn.Do("BRPOP", args...)
 	if err != nil {
-		return nil, err
+		return "", nil, err
 	}
 	if messageJSON == nil {
-		return nil, fmt.Errorf("null message received from redis")
+		return "", nil, fmt.Errorf("null message received from redis")
 	}
 	messageList := messageJSON.([]interface{})
-	if string(messageList[0].([]byte)) != cb.QueueName {
-		return nil, fmt.Errorf("not a celery message: %v", messageList[0])
+	popped := string(messageList[0].([]byte))
+	for i, key := range keys {
+		if key == popped {
+			return names[i], messageList[1].([]byte), nil
+		}
 	}
-	var message CeleryMessageV2
-	if err := json.Unmarshal(messageList[1].([]byte), &message); err != nil {
+	return "", nil, fmt.Errorf("not a celery message: %v", messageList[0])
+}
+
+// popUnackedScript pops message from first non-empty list of KEYS[4:] and stores it
+// in unacked hash KEYS[1] and index KEYS[2] in one step, so message is not lost when
+// worker stops in between. Message which is not json is moved to dead letter list KEYS[3].
+// ARGV is time of fetch, delivery tag for message without one and names of queues of KEYS[4:].
+// It returns {queue, message, delivery tag}, delivery tag is empty for dead letter.
+var popUnackedScript = redis.NewScript(-1, `
+for i = 4, #KEYS do
+	local message = redis.call('RPOP', KEYS[i])
+	if message then
+		local queue = ARGV[i - 1]
+		local ok, envelope = pcall(cjson.decode, message)
+		if not ok or type(envelope) ~= 'table' then
+			redis.call('LPUSH', KEYS[3], message)
+			return {queue, message, ''}
+		end
+		local tag = ARGV[2]
+		local exchange = ''
+		local properties = envelope['properties']
+		if type(properties) == 'table' then
+			if type(properties['delivery_tag']) == 'string' and properties['delivery_tag'] ~= '' then
+				tag = properties['delivery_tag']
+			end
+			local info = properties['delivery_info']
+			if type(info) == 'table' and type(info['exchange']) == 'string' then
+				exchange = info['exchange']
+			end
+		end
+		local unacked = '[' .. message .. ',' .. cjson.encode(exchange) .. ',' .. cjson.encode(queue) .. ']'
+		redis.call('HSET', KEYS[1], tag, unacked)
+		redis.call('ZADD', KEYS[2], ARGV[1], tag)
+		return {queue, message, tag}
+	end
+end
+return false
+`)
+
+// popUnacked pops message from redis queue and stores it in unacked hash
+// under its delivery tag, in the same format as celery redis transport.
+// Unlike pop it does not block waiting for message.
+func (cb *RedisCeleryBroker) popUnacked() ([]byte, Delivery, error) {
+	keys, names, err := cb.popKeys()
+	if err != nil {
+		return nil, nil, err
+	}
+	args := make([]interface{}, 0, 2*len(keys)+6)
+	args = append(args, len(keys)+3, cb.UnackedKey, cb.UnackedIndexKey, cb.DeadLetterKey)
+	for _, key := range keys {
+		args = append(args, key)
+	}
+	args = append(args, float64(time.Now().UnixNano())/1e9, uuid.New().String())
+	for _, name := range names {
+		args = append(args, name)
+	}
+	conn := cb.Get()
+	defer conn.Close()
+	reply, err := redis.Values(popUnackedScript.Do(conn, args...))
+	if err == redis.ErrNil {
+		return nil, nil, fmt.Errorf("null message received from redis")
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	var queueName, tag string
+	var messageBytes []byte
+	if _, err := redis.Scan(reply, &queueName, &messageBytes, &tag); err != nil {
+		return nil, nil, err
+	}
+	if tag == "" {
+		return nil, nil, fmt.Errorf("message from %s is not json, moved to %s", queueName, cb.DeadLetterKey)
+	}
+	return messageBytes, &redisDelivery{broker: cb, tag: tag}, nil
+}
+
+// deadLetterScript moves message from unacked store to dead letter list
+var deadLetterScript = redis.NewScript(3, `
+redis.call('HDEL', KEYS[1], ARGV[1])
+redis.call('ZREM', KEYS[2], ARGV[1])
+redis.call('LPUSH', KEYS[3], ARGV[2])
+return 1
+`)
+
+// deadLetter moves message with delivery tag to dead letter list,
+// tag is empty for message which is not in unacked store
+func (cb *RedisCeleryBroker) deadLetter(tag string, messageBytes []byte) error {
+	conn := cb.Get()
+	defer conn.Close()
+	_, err := deadLetterScript.Do(conn, cb.UnackedKey, cb.UnackedIndexKey, cb.DeadLetterKey, tag, messageBytes)
+	return err
+}
+
+// AddConsumer starts consuming queue
+func (cb *RedisCeleryBroker) AddConsumer(queue string) error {
+	cb.queueLock.Lock()
+	defer cb.queueLock.Unlock()
+	if cb.Queues == nil {
+		cb.Queues = []string{cb.QueueName}
+	}
+	for _, consumed := range cb.Queues {
+		if consumed == queue {
+			return nil
+		}
+	}
+	cb.Queues = append(cb.Queues, queue)
+	return nil
+}
+
+// CancelConsumer stops consuming queue
+func (cb *RedisCeleryBroker) CancelConsumer(queue string) error {
+	cb.queueLock.Lock()
+	defer cb.queueLock.Unlock()
+	if cb.Queues == nil {
+		cb.Queues = []string{cb.QueueName}
+	}
+	queues := make([]string, 0, len(cb.Queues))
+	for _, consumed := range cb.Queues {
+		if consumed != queue {
+			queues = append(queues, consumed)
+		}
+	}
+	cb.Queues = queues
+	return nil
+}
+
+// ConsumingQueues returns names of consumed queues
+func (cb *RedisCeleryBroker) ConsumingQueues() []string {
+	cb.queueLock.RLock()
+	defer cb.queueLock.RUnlock()
+	if cb.Queues == nil {
+		return []string{cb.QueueName}
+	}
+	return append([]string(nil), cb.Queues...)
+}
+
+// ConsumeControl subscribes to celery remote control broadcasts
+func (cb *RedisCeleryBroker) ConsumeControl(ctx context.Context, hostname string) (<-chan *ControlMessage, error) {
+	conn := cb.Get()
+	psc := redis.PubSubConn{Conn: conn}
+	if err := psc.Subscribe(cb.ControlChannel); err != nil {
+		conn.Close()
 		return nil, err
 	}
-	return &message, nil
+	go func() {
+		<-ctx.Done()
+		psc.Unsubscribe()
+	}()
+	messages := make(chan *ControlMessage)
+	go func() {
+		defer close(messages)
+		defer conn.Close()
+		for {
+			switch v := psc.Receive().(type) {
+			case redis.Message:
+				var envelope CeleryEnvelope
+				if err := json.Unmarshal(v.Data, &envelope); err != nil {
+					log.Printf("failed to decode control message: %+v", err)
+					continue
+				}
+				message, err := decodeControlMessage([]byte(envelope.Body), envelope.Properties.BodyEncoding)
+				if err != nil {
+					log.Printf("failed to decode control message: %+v", err)
+					continue
+				}
+				select {
+				case messages <- message:
+				case <-ctx.Done():
+					return
+				}
+			case redis.Subscription:
+				if v.Count == 0 {
+					return
+				}
+			case error:
+				if ctx.Err() == nil {
+					log.Printf("control subscription closed: %+v", v)
+				}
+				return
+			}
+		}
+	}()
+	return messages, nil
 }
 
+// kombuBindingPrefix is prefix of redis set in which kombu keeps bindings of exchange
+// as routing key, pattern and queue joined by prioritySeparator
+const kombuBindingPrefix = "_kombu.binding."
 
+// ReplyControl pushes reply to control command to queues bound to reply exchange
+// with routing key of its caller, the way kombu routes direct exchanges
+func (cb *RedisCeleryBroker) ReplyControl(message *ControlMessage, hostname string, reply map[string]interface{}) error {
+	if message.ReplyTo == nil {
+		return nil
+	}
+	body, err := controlReplyBody(hostname, reply)
+	if err != nil {
+		return err
+	}
+	jsonBytes, err := json.Marshal(&CeleryEnvelope{
+		Body:    base64.StdEncoding.EncodeToString(body),
+		Headers: map[string]interface{}{"ticket": message.Ticket},
+		Properties: CeleryPropertiesV2{
+			BodyEncoding:  "base64",
+			CorrelationID: message.Ticket,
+			DeliveryInfo: CeleryDeliveryInfoV2{
+				Exchange:   message.ReplyTo.Exchange,
+				RoutingKey: message.ReplyTo.RoutingKey,
+			},
+			DeliveryMode: 1,
+			DeliveryTag:  uuid.New().String(),
+		},
+		ContentType:     "application/json",
+		ContentEncoding: "utf-8",
+	})
+	if err != nil {
+		return err
+	}
+	conn := cb.Get()
+	defer conn.Close()
+	bindings, err := redis.Strings(conn.Do("SMEMBERS", kombuBindingPrefix+message.ReplyTo.Exchange))
+	if err != nil {
+		return err
+	}
+	for _, binding := range bindings {
+		fields := strings.Split(binding, prioritySeparator)
+		if len(fields) != 3 || fields[0] != message.ReplyTo.RoutingKey {
+			continue
+		}
+		if _, err := conn.Do("LPUSH", fields[2], jsonBytes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// moveDelayedScript moves messages due by redis server time from sorted set to queue
+var moveDelayedScript = redis.NewScript(2, `
+local now = redis.call('TIME')
+local score = tonumber(now[1]) + tonumber(now[2]) / 1000000
+local due = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', score, 'LIMIT', 0, tonumber(ARGV[1]))
+for _, message in ipairs(due) do
+	redis.call('ZREM', KEYS[1], message)
+	redis.call('LPUSH', KEYS[2], message)
+end
+return #due
+`)
+
+// MoveDelayed moves delayed messages of consumed queues whose ETA has come
+// to their queues and returns number of moved messages
+func (cb *RedisCeleryBroker) MoveDelayed() (int, error) {
+	if !cb.DelayedDelivery {
+		return 0, nil
+	}
+	steps := cb.PrioritySteps
+	if len(steps) == 0 {
+		steps = []int{0}
+	}
+	conn := cb.Get()
+	defer conn.Close()
+	count := 0
+	for _, queueName := range cb.consumedQueues() {
+		for _, step := range steps {
+			listName := cb.priorityQueue(queueName, step)
+			moved, err := redis.Int(moveDelayedScript.Do(conn, cb.DelayedKeyPrefix+listName, listName, 1000))
+			if err != nil {
+				return count, err
+			}
+			count += moved
+		}
+	}
+	return count, nil
+}
+
+// restoreScript pushes unacked message back to its queue
+// unless another worker already acknowledged or restored it
+var restoreScript = redis.NewScript(3, `
+if redis.call('HDEL', KEYS[1], ARGV[1]) == 1 then
+	redis.call('ZREM', KEYS[2], ARGV[1])
+	redis.call('RPUSH', KEYS[3], ARGV[2])
+	return 1
+end
+return 0
+`)
+
+// restore moves unacked message back to the queue it was fetched from
+func (cb *RedisCeleryBroker) restore(conn redis.Conn, tag string) (bool, error) {
+	payload, err := redis.Bytes(conn.Do("HGET", cb.UnackedKey, tag))
+	if err == redis.ErrNil {
+		// already acknowledged, only the index entry is left
+		_, err = conn.Do("ZREM", cb.UnackedIndexKey, tag)
+		return false, err
+	}
+	if err != nil {
+		return false, err
+	}
+	var entry []json.RawMessage
+	if err := json.Unmarshal(payload, &entry); err != nil {
+		return false, err
+	}
+	if len(entry) != 3 {
+		return false, fmt.Errorf("unexpected unacked entry length %d", len(entry))
+	}
+	queueName := cb.QueueName
+	var routingKey string
+	if err := json.Unmarshal(entry[2], &routingKey); err == nil && routingKey != "" {
+		queueName = routingKey
+	}
+	queueName = cb.priorityQueue(queueName, messagePriority(entry[0]))
+	// push to the consuming end so restored message is processed next
+	restored, err := redis.Int(restoreScript.Do(conn, cb.UnackedKey, cb.UnackedIndexKey, queueName, tag, []byte(entry[0])))
+	return restored == 1, err
+}
+
+// RestoreUnacked restores messages whose visibility timeout has passed
+// and returns number of restored messages
+func (cb *RedisCeleryBroker) RestoreUnacked() (int, error) {
+	conn := cb.Get()
+	defer conn.Close()
+	deadline := float64(time.Now().Add(-cb.VisibilityTimeout).UnixNano()) / 1e9
+	tags, err := redis.Strings(conn.Do("ZRANGEBYSCORE", cb.UnackedIndexKey, "-inf", deadline, "LIMIT", 0, 1000))
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, tag := range tags {
+		restored, err := cb.restore(conn, tag)
+		if err != nil {
+			return count, err
+		}
+		if restored {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// redisDelivery is message kept in unacked store of RedisCeleryBroker
+type redisDelivery struct {
+	broker *RedisCeleryBroker
+	tag    string
+}
+
+// Ack removes message from unacked store
+func (d *redisDelivery) Ack() error {
+	conn := d.broker.Get()
+	defer conn.Close()
+	conn.Send("MULTI")
+	conn.Send("HDEL", d.broker.UnackedKey, d.tag)
+	conn.Send("ZREM", d.broker.UnackedIndexKey, d.tag)
+	_, err := conn.Do("EXEC")
+	return err
+}
+
+// Nack restores message to its queue or drops it
+func (d *redisDelivery) Nack(requeue bool) error {
+	if !requeue {
+		return d.Ack()
+	}
+	conn := d.broker.Get()
+	defer conn.Close()
+	_, err := d.broker.restore(conn, d.tag)
+	return err
+}
 
 // NewRedisPool creates pool of redis connections from given connection string
 //
@@ -0,0 +1,76 @@
+// Copyright (c) 2019 Sick Yoon
+// This file is part of gocelery which is released under MIT license.
+// See file LICENSE for full license details.
+
+package gocelery
+
+import (
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/google/uuid"
+)
+
+// slidingWindowScript atomically trims the window, counts executions
+// and records a new one if the quota allows it.
+// It returns {allowed, milliseconds to wait}.
+// Window is measured by clock of redis shared by all workers. Scripts writing after
+// TIME must be replicated by effects, which requires redis 3.2 or newer.
+var slidingWindowScript = redis.NewScript(1, `
+redis.replicate_commands()
+local key = KEYS[1]
+local limit = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local member = ARGV[3]
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+redis.call('ZREMRANGEBYSCORE', key, '-inf', now - window)
+if redis.call('ZCARD', key) < limit then
+	redis.call('ZADD', key, now, member)
+	redis.call('PEXPIRE', key, window)
+	return {1, 0}
+end
+local wait = window
+local oldest = redis.call('ZRANGE', key, 0, 0, 'WITHSCORES')
+if oldest[2] then
+	wait = tonumber(oldest[2]) + window - now
+end
+if wait < 1 then
+	wait = 1
+end
+return {0, wait}
+`)
+
+// RedisRateLimiter is sliding window rate limiter shared through redis, it requires redis 3.2 or newer
+type RedisRateLimiter struct {
+	*redis.Pool
+	KeyPrefix string
+}
+
+// NewRedisRateLimiter creates new RedisRateLimiter with given redis pool
+func NewRedisRateLimiter(conn *redis.Pool) *RedisRateLimiter {
+	return &RedisRateLimiter{
+		Pool:      conn,
+		KeyPrefix: "gocelery-ratelimit-",
+	}
+}
+
+// Allow records an execution for key if the quota allows it
+func (rl *RedisRateLimiter) Allow(key string, limit int, period time.Duration) (bool, time.Duration, error) {
+	if err := (&RateLimit{Limit: limit, Period: period}).validate(); err != nil {
+		return false, 0, err
+	}
+	conn := rl.Get()
+	defer conn.Close()
+	reply, err := redis.Int64s(slidingWindowScript.Do(
+		conn,
+		rl.KeyPrefix+key,
+		limit,
+		period.Milliseconds(),
+		uuid.New().String(),
+	))
+	if err != nil {
+		return false, 0, err
+	}
+	return reply[0] == 1, time.Duration(reply[1]) * time.Millisecond, nil
+}
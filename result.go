@@ -0,0 +1,131 @@
+// Copyright (c) 2019 Sick Yoon
+// This file is part of gocelery which is released under MIT license.
+// See file LICENSE for full license details.
+
+package gocelery
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// TaskFailedError is returned when task finished in FAILURE or REVOKED state
+type TaskFailedError struct {
+	TaskID    string
+	Status    string
+	Result    interface{}
+	Traceback interface{}
+}
+
+func (e *TaskFailedError) Error() string {
+	// celery stores exception as its type and arguments
+	if exc, ok := e.Result.(map[string]interface{}); ok && exc["exc_type"] != nil {
+		return fmt.Sprintf("task %s %s: %v: %v", e.TaskID, e.Status, exc["exc_type"], exc["exc_message"])
+	}
+	return fmt.Sprintf("task %s %s: %v", e.TaskID, e.Status, e.Result)
+}
+
+// ResultTypeError is returned when result cannot be decoded into requested type
+type ResultTypeError struct {
+	TaskID string
+	Result interface{}
+	Err    error
+}
+
+func (e *ResultTypeError) Error() string {
+	return fmt.Sprintf("result %v of task %s: %v", e.Result, e.TaskID, e.Err)
+}
+
+// Unwrap returns decoding error
+func (e *ResultTypeError) Unwrap() error {
+	return e.Err
+}
+
+// newTaskFailedError returns error for result in failed state or nil
+func newTaskFailedError(taskID string, result *ResultMessage) error {
+	switch result.Status {
+	case "FAILURE", "REVOKED":
+		return &TaskFailedError{
+			TaskID:    taskID,
+			Status:    result.Status,
+			Result:    result.Result,
+			Traceback: result.Traceback,
+		}
+	}
+	return nil
+}
+
+// decodeResult decodes result into value pointed to by out through its json representation
+func decodeResult(taskID string, result interface{}, out interface{}) error {
+	// values decoded from kombu type markers, e.g. time.Time, are set as they are
+	if outVal := reflect.ValueOf(out); outVal.Kind() == reflect.Ptr && result != nil {
+		if rv := reflect.ValueOf(result); rv.Type().AssignableTo(outVal.Type().Elem()) {
+			outVal.Elem().Set(rv)
+			return nil
+		}
+	}
+	resBytes, err := json.Marshal(result)
+	if err != nil {
+		return &ResultTypeError{TaskID: taskID, Result: result, Err: err}
+	}
+	if err := json.Unmarshal(resBytes, out); err != nil {
+		return &ResultTypeError{TaskID: taskID, Result: result, Err: err}
+	}
+	return nil
+}
+
+// GetInto waits for result until ctx is done and decodes it into value pointed to by out
+func (ar *AsyncResult) GetInto(ctx context.Context, out interface{}) error {
+	val, err := ar.GetWithContext(ctx)
+	if err != nil {
+		return err
+	}
+	return decodeResult(ar.TaskID, val, out)
+}
+
+// AsyncGetInto decodes result into value pointed to by out if it is available
+func (ar *AsyncResult) AsyncGetInto(out interface{}) error {
+	val, err := ar.AsyncGet()
+	if err != nil {
+		return err
+	}
+	return decodeResult(ar.TaskID, val, out)
+}
+
+// AsyncResultOf is pending result decoded into T
+type AsyncResultOf[T any] struct {
+	*AsyncResult
+}
+
+// NewAsyncResultOf wraps AsyncResult to decode its result into T
+func NewAsyncResultOf[T any](ar *AsyncResult) *AsyncResultOf[T] {
+	return &AsyncResultOf[T]{AsyncResult: ar}
+}
+
+// Get waits for result for period of time set by timeout and decodes it
+func (ar *AsyncResultOf[T]) Get(timeout time.Duration) (T, error) {
+	var out T
+	val, err := ar.AsyncResult.Get(timeout)
+	if err != nil {
+		return out, err
+	}
+	err = decodeResult(ar.TaskID, val, &out)
+	return out, err
+}
+
+// GetWithContext waits for result until ctx is done and decodes it
+func (ar *AsyncResultOf[T]) GetWithContext(ctx context.Context) (T, error) {
+	var out T
+	err := ar.GetInto(ctx, &out)
+	return out, err
+}
+
+// AsyncGet decodes result if it is available
+func (ar *AsyncResultOf[T]) AsyncGet() (T, error) {
+	var out T
+	err := ar.AsyncGetInto(&out)
+	return out, err
+}
@@ -0,0 +1,59 @@
+// Copyright (c) 2019 Sick Yoon
+// This file is part of gocelery which is released under MIT license.
+// See file LICENSE for full license details.
+
+package gocelery
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type point struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+// TestAsyncResultOf tests that results are decoded into requested types
+func TestAsyncResultOf(t *testing.T) {
+	backend := &stubBackend{}
+	backend.SetResult("int", &ResultMessage{Status: "SUCCESS", Result: 3.0})
+	backend.SetResult("point", &ResultMessage{Status: "SUCCESS", Result: map[string]interface{}{"x": 1.0, "y": 2.0}})
+	backend.SetResult("string", &ResultMessage{Status: "SUCCESS", Result: "three"})
+
+	if val, err := NewAsyncResultOf[int](&AsyncResult{TaskID: "int", backend: backend}).Get(time.Second); err != nil || val != 3 {
+		t.Errorf("failed to decode int result: %v %v", val, err)
+	}
+	var p point
+	if err := (&AsyncResult{TaskID: "point", backend: backend}).GetInto(context.Background(), &p); err != nil || p != (point{1, 2}) {
+		t.Errorf("failed to decode struct result: %v %v", p, err)
+	}
+	_, err := NewAsyncResultOf[int](&AsyncResult{TaskID: "string", backend: backend}).AsyncGet()
+	var typeErr *ResultTypeError
+	if !errors.As(err, &typeErr) {
+		t.Errorf("expected type mismatch error, got %v", err)
+	}
+}
+
+// TestAsyncResultFailure tests that failed task is reported without waiting for timeout
+func TestAsyncResultFailure(t *testing.T) {
+	backend := &stubBackend{}
+	backend.SetResult("failed", &ResultMessage{
+		Status: "FAILURE",
+		Result: map[string]interface{}{"exc_type": "ValueError", "exc_message": []interface{}{"bad"}},
+	})
+	start := time.Now()
+	_, err := NewAsyncResultOf[int](&AsyncResult{TaskID: "failed", backend: backend}).Get(5 * time.Second)
+	var failedErr *TaskFailedError
+	if !errors.As(err, &failedErr) || failedErr.Status != "FAILURE" || failedErr.TaskID != "failed" {
+		t.Fatalf("expected task failed error, got %v", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Errorf("failure was reported after %v", time.Since(start))
+	}
+	if failedErr.Error() != "task failed FAILURE: ValueError: [bad]" {
+		t.Errorf("unexpected error message: %s", failedErr.Error())
+	}
+}
@@ -0,0 +1,367 @@
+// Copyright (c) 2019 Sick Yoon
+// This file is part of gocelery which is released under MIT license.
+// See file LICENSE for full license details.
+
+package gocelery
+
+import (
+	"context"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// ContextTask is task struct whose fields are bound from message arguments.
+// Fields are tagged `celery:"name,required,default=value"`; positional arguments
+// fill tagged fields in declaration order and keyword arguments fill them by name.
+// Registered struct is copied for every run, so its untagged fields can hold dependencies.
+type ContextTask interface {
+	Run(ctx context.Context) (interface{}, error)
+}
+
+// celeryField is struct field bound from task argument
+type celeryField struct {
+	index        int
+	name         string
+	required     bool
+	defaultValue *string
+}
+
+// celeryFields returns fields of struct tagged with celery tag in declaration order
+func celeryFields(structType reflect.Type) []celeryField {
+	var fields []celeryField
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		tag, ok := field.Tag.Lookup("celery")
+		if !ok || tag == "-" || field.PkgPath != "" {
+			continue
+		}
+		parts := strings.Split(tag, ",")
+		celeryField := celeryField{index: i, name: parts[0]}
+		if celeryField.name == "" {
+			celeryField.name = field.Name
+		}
+		for _, option := range parts[1:] {
+			switch {
+			case option == "required":
+				celeryField.required = true
+			case strings.HasPrefix(option, "default="):
+				defaultValue := strings.TrimPrefix(option, "default=")
+				celeryField.defaultValue = &defaultValue
+			}
+		}
+		fields = append(fields, celeryField)
+	}
+	return fields
+}
+
+// runContextTask runs copy of registered task struct with fields bound from arguments
+func runContextTask(ctx context.Context, task ContextTask, args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+	prototype := reflect.ValueOf(task)
+	isPtr := prototype.Kind() == reflect.Ptr
+	if isPtr {
+		prototype = prototype.Elem()
+	}
+	if prototype.Kind() != reflect.Struct {
+		return task.Run(ctx)
+	}
+	instance := reflect.New(prototype.Type())
+	instance.Elem().Set(prototype)
+	if err := bindTaskArgs(instance.Elem(), args, kwargs); err != nil {
+		return nil, err
+	}
+	// task registered as struct value has value receiver
+	if !isPtr {
+		return instance.Elem().Interface().(ContextTask).Run(ctx)
+	}
+	return instance.Interface().(ContextTask).Run(ctx)
+}
+
+// bindTaskArgs sets tagged fields of struct value from positional and keyword arguments
+func bindTaskArgs(structVal reflect.Value, args []interface{}, kwargs map[string]interface{}) error {
+	fields := celeryFields(structVal.Type())
+	if len(args) > len(fields) {
+		return &ArgumentError{Err: fmt.Errorf("takes %d positional arguments but %d were given", len(fields), len(args))}
+	}
+	bound := make(map[string]bool, len(fields))
+	for i, arg := range args {
+		if err := bindField(structVal, fields[i], arg); err != nil {
+			return err
+		}
+		bound[fields[i].name] = true
+	}
+	for name, val := range kwargs {
+		var field *celeryField
+		for i := range fields {
+			if fields[i].name == name {
+				field = &fields[i]
+				break
+			}
+		}
+		if field == nil {
+			return &ArgumentError{Err: fmt.Errorf("got an unexpected keyword argument '%s'", name)}
+		}
+		if bound[name] {
+			return &ArgumentError{Err: fmt.Errorf("got multiple values for argument '%s'", name)}
+		}
+		if err := bindField(structVal, *field, val); err != nil {
+			return err
+		}
+		bound[name] = true
+	}
+	for _, field := range fields {
+		if bound[field.name] {
+			continue
+		}
+		if field.defaultValue != nil {
+			if err := bindDefault(structVal.Field(field.index), *field.defaultValue); err != nil {
+				return &ArgumentError{Err: fmt.Errorf("invalid default of argument '%s': %v", field.name, err)}
+			}
+			continue
+		}
+		if field.required {
+			return &ArgumentError{Err: fmt.Errorf("missing required argument '%s'", field.name)}
+		}
+	}
+	return nil
+}
+
+// bindField sets field of struct value from argument
+func bindField(structVal reflect.Value, field celeryField, val interface{}) error {
+	if err := coerceValue(val, structVal.Field(field.index)); err != nil {
+		var argErr *ArgumentError
+		if e, ok := err.(*ArgumentError); ok {
+			argErr = e
+		} else {
+			argErr = &ArgumentError{Err: err}
+		}
+		argErr.Err = fmt.Errorf("argument '%s': %v", field.name, argErr.Err)
+		return argErr
+	}
+	return nil
+}
+
+// bindDefault sets target from default value written in tag
+func bindDefault(target reflect.Value, defaultValue string) error {
+	switch target.Kind() {
+	case reflect.String:
+		target.SetString(defaultValue)
+		return nil
+	case reflect.Bool:
+		b, err := strconv.ParseBool(defaultValue)
+		if err != nil {
+			return err
+		}
+		target.SetBool(b)
+		return nil
+	}
+	if isNumericKind(target.Kind()) {
+		return coerceValue(defaultValue, target)
+	}
+	return json.Unmarshal([]byte(defaultValue), target.Addr().Interface())
+}
+
+// isNumericKind reports whether kind is integer or float kind
+func isNumericKind(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// toFloat converts numeric argument or numeric string to float64
+func toFloat(val interface{}) (float64, bool) {
+	switch v := val.(type) {
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		return f, err == nil
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	case bool, nil:
+		return 0, false
+	}
+	rv := reflect.ValueOf(val)
+	switch {
+	case rv.CanInt():
+		return float64(rv.Int()), true
+	case rv.CanUint():
+		return float64(rv.Uint()), true
+	case rv.CanFloat():
+		return rv.Float(), true
+	}
+	return 0, false
+}
+
+// setNumber sets integer or text unmarshaler target from json.Number without going through float64.
+// It returns false when number should be converted like other values.
+func setNumber(number json.Number, target reflect.Value) (bool, error) {
+	switch target.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(number.String(), 10, 64)
+		if errors.Is(err, strconv.ErrRange) {
+			return true, fmt.Errorf("%s overflows %s", number, target.Type())
+		}
+		if err != nil {
+			// fractions and exponents are checked after conversion to float64
+			return false, nil
+		}
+		if target.OverflowInt(i) {
+			return true, fmt.Errorf("%s overflows %s", number, target.Type())
+		}
+		target.SetInt(i)
+		return true, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if strings.HasPrefix(number.String(), "-") {
+			return true, fmt.Errorf("cannot use negative %s as %s", number, target.Type())
+		}
+		u, err := strconv.ParseUint(number.String(), 10, 64)
+		if errors.Is(err, strconv.ErrRange) {
+			return true, fmt.Errorf("%s overflows %s", number, target.Type())
+		}
+		if err != nil {
+			return false, nil
+		}
+		if target.OverflowUint(u) {
+			return true, fmt.Errorf("%s overflows %s", number, target.Type())
+		}
+		target.SetUint(u)
+		return true, nil
+	}
+	if target.Type() == decimalType {
+		target.SetString(number.String())
+		return true, nil
+	}
+	// big.Int, big.Float and big.Rat are parsed from number text
+	if target.CanAddr() {
+		if unmarshaler, ok := target.Addr().Interface().(encoding.TextUnmarshaler); ok {
+			if err := unmarshaler.UnmarshalText([]byte(number)); err != nil {
+				return true, fmt.Errorf("cannot use %s as %s: %v", number, target.Type(), err)
+			}
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// coerceValue sets target from decoded json value converting numbers to target kind
+func coerceValue(val interface{}, target reflect.Value) error {
+	if val == nil {
+		target.Set(reflect.Zero(target.Type()))
+		return nil
+	}
+	// values decoded from kombu type markers, e.g. time.Time, are set as they are
+	if rv := reflect.ValueOf(val); rv.Type().AssignableTo(target.Type()) {
+		target.Set(rv)
+		return nil
+	}
+	kind := target.Kind()
+	if number, ok := val.(json.Number); ok {
+		if done, err := setNumber(number, target); done {
+			return err
+		}
+	}
+	switch {
+	case kind == reflect.Ptr:
+		elem := reflect.New(target.Type().Elem())
+		if err := coerceValue(val, elem.Elem()); err != nil {
+			return err
+		}
+		target.Set(elem)
+		return nil
+	case kind == reflect.Interface:
+		target.Set(reflect.ValueOf(val))
+		return nil
+	case isNumericKind(kind):
+		f, ok := toFloat(val)
+		if !ok {
+			return fmt.Errorf("cannot use %v (%T) as %s", val, val, target.Type())
+		}
+		switch kind {
+		case reflect.Float32, reflect.Float64:
+			if target.OverflowFloat(f) {
+				return fmt.Errorf("%v overflows %s", val, target.Type())
+			}
+			target.SetFloat(f)
+			return nil
+		}
+		if f != math.Trunc(f) {
+			return fmt.Errorf("cannot use fractional %v as %s", val, target.Type())
+		}
+		switch kind {
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			if f < 0 || f >= math.MaxUint64 || target.OverflowUint(uint64(f)) {
+				return fmt.Errorf("%v overflows %s", val, target.Type())
+			}
+			target.SetUint(uint64(f))
+		default:
+			if f < math.MinInt64 || f >= math.MaxInt64 || target.OverflowInt(int64(f)) {
+				return fmt.Errorf("%v overflows %s", val, target.Type())
+			}
+			target.SetInt(int64(f))
+		}
+		return nil
+	case kind == reflect.String:
+		s, ok := val.(string)
+		if !ok {
+			return fmt.Errorf("cannot use %v (%T) as %s", val, val, target.Type())
+		}
+		target.SetString(s)
+		return nil
+	case kind == reflect.Bool:
+		b, ok := val.(bool)
+		if !ok {
+			return fmt.Errorf("cannot use %v (%T) as %s", val, val, target.Type())
+		}
+		target.SetBool(b)
+		return nil
+	case kind == reflect.Slice && target.Type().Elem().Kind() != reflect.Uint8:
+		items, ok := val.([]interface{})
+		if !ok {
+			return fmt.Errorf("cannot use %v (%T) as %s", val, val, target.Type())
+		}
+		slice := reflect.MakeSlice(target.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := coerceValue(item, slice.Index(i)); err != nil {
+				return fmt.Errorf("item %d: %v", i, err)
+			}
+		}
+		target.Set(slice)
+		return nil
+	case kind == reflect.Map && target.Type().Key().Kind() == reflect.String:
+		items, ok := val.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("cannot use %v (%T) as %s", val, val, target.Type())
+		}
+		m := reflect.MakeMapWithSize(target.Type(), len(items))
+		for key, item := range items {
+			elem := reflect.New(target.Type().Elem()).Elem()
+			if err := coerceValue(item, elem); err != nil {
+				return fmt.Errorf("key %s: %v", key, err)
+			}
+			m.SetMapIndex(reflect.ValueOf(key).Convert(target.Type().Key()), elem)
+		}
+		target.Set(m)
+		return nil
+	case kind == reflect.Struct && len(celeryFields(target.Type())) > 0:
+		items, ok := val.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("cannot use %v (%T) as %s", val, val, target.Type())
+		}
+		return bindTaskArgs(target, nil, items)
+	}
+	// structs without celery tags, arrays and other types are decoded from json
+	valBytes, err := json.Marshal(val)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(valBytes, target.Addr().Interface())
+}
@@ -0,0 +1,118 @@
+// Copyright (c) 2019 Sick Yoon
+// This file is part of gocelery which is released under MIT license.
+// See file LICENSE for full license details.
+
+package gocelery
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+type shippingAddress struct {
+	City string `celery:"city,required"`
+	Zip  string `celery:"zip,default=00000"`
+}
+
+// shipTask is task struct bound from message arguments
+type shipTask struct {
+	OrderID  int64            `celery:"order_id,required"`
+	Quantity uint8            `celery:"quantity,default=1"`
+	Express  bool             `celery:"express"`
+	Weights  []float32        `celery:"weights"`
+	Address  *shippingAddress `celery:"address"`
+
+	// prefix is dependency kept from registered task
+	prefix string
+}
+
+func (t *shipTask) Run(ctx context.Context) (interface{}, error) {
+	if ctx == nil {
+		return nil, errors.New("context is not passed")
+	}
+	total := float32(0)
+	for _, weight := range t.Weights {
+		total += weight
+	}
+	city, zip := "", ""
+	if t.Address != nil {
+		city, zip = t.Address.City, t.Address.Zip
+	}
+	return map[string]interface{}{
+		"label":    t.prefix + city + zip,
+		"order":    t.OrderID,
+		"quantity": t.Quantity,
+		"express":  t.Express,
+		"weight":   total,
+	}, nil
+}
+
+// TestContextTaskBinding tests that task struct fields are bound by celery tags
+func TestContextTaskBinding(t *testing.T) {
+	celeryWorker := NewCeleryWorker(&stubBroker{}, &stubBackend{}, 1)
+	celeryWorker.Register("ship", &shipTask{prefix: "to "})
+	message := getTaskMessageV2WithKwargs([]interface{}{42.0}, map[string]interface{}{
+		"express": true,
+		"weights": []interface{}{1.5, 2.0},
+		"address": map[string]interface{}{"city": "Seoul"},
+	})
+	defer releaseTaskMessageV2(message)
+	res, err := celeryWorker.RunTaskV2("ship", "id", message)
+	if err != nil {
+		t.Fatalf("failed to run task struct: %v", err)
+	}
+	result := res.Result.(map[string]interface{})
+	if result["label"] != "to Seoul00000" || result["order"] != int64(42) || result["quantity"] != uint8(1) ||
+		result["express"] != true || result["weight"] != float32(3.5) {
+		t.Errorf("unexpected result %v", result)
+	}
+}
+
+// TestContextTaskBindingErrors tests that invalid arguments are reported
+func TestContextTaskBindingErrors(t *testing.T) {
+	celeryWorker := NewCeleryWorker(&stubBroker{}, &stubBackend{}, 1)
+	celeryWorker.Register("ship", &shipTask{})
+	for _, tc := range []struct {
+		name   string
+		args   []interface{}
+		kwargs map[string]interface{}
+	}{
+		{name: "missing required", args: []interface{}{}},
+		{name: "fractional integer", args: []interface{}{1.5}},
+		{name: "overflow", args: []interface{}{1.0, 300.0}},
+		{name: "negative unsigned", args: []interface{}{1.0, -1.0}},
+		{name: "wrong type", args: []interface{}{1.0}, kwargs: map[string]interface{}{"express": "yes"}},
+		{name: "nested required", args: []interface{}{1.0}, kwargs: map[string]interface{}{"address": map[string]interface{}{}}},
+		{name: "unexpected keyword", args: []interface{}{1.0}, kwargs: map[string]interface{}{"color": "red"}},
+		{name: "duplicate", args: []interface{}{1.0}, kwargs: map[string]interface{}{"order_id": 2.0}},
+	} {
+		message := getTaskMessageV2WithKwargs(tc.args, tc.kwargs)
+		res, err := celeryWorker.RunTaskV2("ship", "id", message)
+		releaseTaskMessageV2(message)
+		if !isTypeErrorResult(res, err) {
+			t.Errorf("test '%s': expected TypeError failure, got %v %v", tc.name, res, err)
+		}
+	}
+}
+
+// greetTask is task struct with value receiver
+type greetTask struct {
+	Name string `celery:"name,required"`
+}
+
+func (t greetTask) Run(ctx context.Context) (interface{}, error) {
+	return "hello " + t.Name, nil
+}
+
+// TestContextTaskValueBinding tests that task struct registered by value is bound too
+func TestContextTaskValueBinding(t *testing.T) {
+	celeryWorker := NewCeleryWorker(&stubBroker{}, &stubBackend{}, 1)
+	celeryWorker.Register("greet", greetTask{})
+	message := getTaskMessageV2("go")
+	defer releaseTaskMessageV2(message)
+	res, err := celeryWorker.RunTaskV2("greet", "id", message)
+	if err != nil || res.Result != "hello go" {
+		t.Errorf("unexpected result %v: %v", res, err)
+	}
+}
@@ -0,0 +1,153 @@
+// Copyright (c) 2019 Sick Yoon
+// This file is part of gocelery which is released under MIT license.
+// See file LICENSE for full license details.
+
+package gocelery
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// TaskParam declares name of function task parameter and its default value
+type TaskParam struct {
+	Name     string
+	Default  interface{}
+	Optional bool
+}
+
+// Param declares required parameter
+func Param(name string) TaskParam {
+	return TaskParam{Name: name}
+}
+
+// OptionalParam declares parameter taking defaultValue when it is not passed
+func OptionalParam(name string, defaultValue interface{}) TaskParam {
+	return TaskParam{Name: name, Default: defaultValue, Optional: true}
+}
+
+// namedTaskFunc is function task with declared parameter names
+type namedTaskFunc struct {
+	fn     reflect.Value
+	params []TaskParam
+}
+
+// newNamedTaskFunc checks that params match parameters of fn
+func newNamedTaskFunc(fn interface{}, params []TaskParam) (*namedTaskFunc, error) {
+	fnVal := reflect.ValueOf(fn)
+	if fnVal.Kind() != reflect.Func {
+		return nil, fmt.Errorf("task must be a function, got %T", fn)
+	}
+	if fnVal.Type().NumIn() != len(params) || fnVal.Type().IsVariadic() {
+		return nil, fmt.Errorf("function takes %d parameters but %d names were declared", fnVal.Type().NumIn(), len(params))
+	}
+	optional := false
+	names := make(map[string]bool, len(params))
+	for _, param := range params {
+		if names[param.Name] {
+			return nil, fmt.Errorf("duplicate parameter '%s'", param.Name)
+		}
+		names[param.Name] = true
+		if optional && !param.Optional {
+			return nil, fmt.Errorf("required parameter '%s' follows optional parameter", param.Name)
+		}
+		optional = param.Optional
+	}
+	return &namedTaskFunc{fn: fnVal, params: params}, nil
+}
+
+func (t *namedTaskFunc) runTyped(args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+	if len(args) > len(t.params) {
+		return nil, &ArgumentError{Err: fmt.Errorf("takes %d positional arguments but %d were given", len(t.params), len(args))}
+	}
+	values := make([]interface{}, len(t.params))
+	passed := make([]bool, len(t.params))
+	for i, arg := range args {
+		values[i], passed[i] = arg, true
+	}
+	for name, val := range kwargs {
+		index := -1
+		for i, param := range t.params {
+			if param.Name == name {
+				index = i
+				break
+			}
+		}
+		if index < 0 {
+			return nil, &ArgumentError{Err: fmt.Errorf("got an unexpected keyword argument '%s'", name)}
+		}
+		if passed[index] {
+			return nil, &ArgumentError{Err: fmt.Errorf("got multiple values for argument '%s'", name)}
+		}
+		values[index], passed[index] = val, true
+	}
+
+	in := make([]reflect.Value, len(t.params))
+	for i, param := range t.params {
+		paramType := t.fn.Type().In(i)
+		if !passed[i] {
+			if !param.Optional {
+				return nil, &ArgumentError{Err: fmt.Errorf("missing required argument '%s'", param.Name)}
+			}
+			if param.Default == nil {
+				in[i] = reflect.Zero(paramType)
+				continue
+			}
+			defaultVal := reflect.ValueOf(param.Default)
+			if !defaultVal.Type().AssignableTo(paramType) {
+				if !defaultVal.Type().ConvertibleTo(paramType) {
+					return nil, &ArgumentError{Err: fmt.Errorf("default %v of argument '%s' is not %s", param.Default, param.Name, paramType)}
+				}
+				defaultVal = defaultVal.Convert(paramType)
+			}
+			in[i] = defaultVal
+			continue
+		}
+		in[i] = reflect.New(paramType).Elem()
+		if err := coerceValue(values[i], in[i]); err != nil {
+			return nil, &ArgumentError{Err: fmt.Errorf("argument '%s': %v", param.Name, err)}
+		}
+	}
+
+	return reflectionResult(t.fn.Call(in))
+}
+
+// RegisterParams registers function task with declared parameter names,
+// so that it can be called with keyword arguments
+func (w *CeleryWorker) RegisterParams(name string, fn interface{}, params ...TaskParam) error {
+	task, err := newNamedTaskFunc(fn, params)
+	if err != nil {
+		return err
+	}
+	w.Register(name, task)
+	return nil
+}
+
+// paramsStructType returns struct type of the only parameter of function
+// when it is params struct with celery tags
+func paramsStructType(fnType reflect.Type) (reflect.Type, bool) {
+	if fnType.NumIn() != 1 {
+		return nil, false
+	}
+	paramType := fnType.In(0)
+	if paramType.Kind() == reflect.Ptr {
+		paramType = paramType.Elem()
+	}
+	if paramType.Kind() != reflect.Struct || len(celeryFields(paramType)) == 0 {
+		return nil, false
+	}
+	return paramType, true
+}
+
+// runParamsStructFunc calls function taking params struct bound from arguments
+func runParamsStructFunc(taskFunc *reflect.Value, structType reflect.Type, args []interface{}, kwargs map[string]interface{}) (*ResultMessage, error) {
+	params := reflect.New(structType)
+	if err := bindTaskArgs(params.Elem(), args, kwargs); err != nil {
+		return getTaskResultMessage(nil, err)
+	}
+	in := params
+	if taskFunc.Type().In(0).Kind() != reflect.Ptr {
+		in = params.Elem()
+	}
+	return getTaskResultMessage(reflectionResult(taskFunc.Call([]reflect.Value{in})))
+}
@@ -0,0 +1,89 @@
+// Copyright (c) 2019 Sick Yoon
+// This file is part of gocelery which is released under MIT license.
+// See file LICENSE for full license details.
+
+package gocelery
+
+import (
+	"testing"
+)
+
+func greet(name string, greeting string, times int) string {
+	result := ""
+	for i := 0; i < times; i++ {
+		result += greeting + " " + name + "!"
+	}
+	return result
+}
+
+type greetParams struct {
+	Name     string `celery:"name,required"`
+	Greeting string `celery:"greeting,default=hello"`
+}
+
+// TestRegisterParams tests that keyword arguments are mapped onto declared parameters
+func TestRegisterParams(t *testing.T) {
+	celeryWorker := NewCeleryWorker(&stubBroker{}, &stubBackend{}, 1)
+	err := celeryWorker.RegisterParams("greet", greet, Param("name"), OptionalParam("greeting", "hi"), OptionalParam("times", 1))
+	if err != nil {
+		t.Fatalf("failed to register task: %v", err)
+	}
+	for _, tc := range []struct {
+		name     string
+		args     []interface{}
+		kwargs   map[string]interface{}
+		expected string
+	}{
+		{name: "positional", args: []interface{}{"go", "hey", 2.0}, expected: "hey go!hey go!"},
+		{name: "keyword", args: []interface{}{}, kwargs: map[string]interface{}{"name": "go", "times": 1.0}, expected: "hi go!"},
+		{name: "mixed with defaults", args: []interface{}{"go"}, kwargs: map[string]interface{}{"greeting": "yo"}, expected: "yo go!"},
+	} {
+		message := getTaskMessageV2WithKwargs(tc.args, tc.kwargs)
+		if message.Args == nil {
+			message.Args = []interface{}{}
+		}
+		res, err := celeryWorker.RunTaskV2("greet", "id", message)
+		releaseTaskMessageV2(message)
+		if err != nil || res.Result != tc.expected {
+			t.Errorf("test '%s': unexpected result %v: %v", tc.name, res, err)
+		}
+	}
+	for _, tc := range []struct {
+		name   string
+		args   []interface{}
+		kwargs map[string]interface{}
+	}{
+		{name: "missing", args: []interface{}{}},
+		{name: "unknown", args: []interface{}{"go"}, kwargs: map[string]interface{}{"color": "red"}},
+		{name: "duplicate", args: []interface{}{"go"}, kwargs: map[string]interface{}{"name": "gopher"}},
+		{name: "too many", args: []interface{}{"go", "hey", 1.0, 2.0}},
+	} {
+		message := getTaskMessageV2WithKwargs(tc.args, tc.kwargs)
+		res, err := celeryWorker.RunTaskV2("greet", "id", message)
+		releaseTaskMessageV2(message)
+		if !isTypeErrorResult(res, err) {
+			t.Errorf("test '%s': expected TypeError failure, got %v %v", tc.name, res, err)
+		}
+	}
+	if err := celeryWorker.RegisterParams("greet", greet, Param("name")); err == nil {
+		t.Error("expected error for missing parameter names")
+	}
+	if err := celeryWorker.RegisterParams("greet", greet, OptionalParam("name", ""), Param("greeting"), Param("times")); err == nil {
+		t.Error("expected error for required parameter after optional one")
+	}
+}
+
+// TestParamsStructFunc tests that function taking params struct is called with keyword arguments
+func TestParamsStructFunc(t *testing.T) {
+	celeryWorker := NewCeleryWorker(&stubBroker{}, &stubBackend{}, 1)
+	celeryWorker.Register("greet", func(params *greetParams) string {
+		return params.Greeting + " " + params.Name
+	})
+	message := getTaskMessageV2WithKwargs([]interface{}{}, map[string]interface{}{"name": "go"})
+	defer releaseTaskMessageV2(message)
+	message.Args = []interface{}{}
+	res, err := celeryWorker.RunTaskV2("greet", "id", message)
+	if err != nil || res.Result != "hello go" {
+		t.Errorf("unexpected result %v: %v", res, err)
+	}
+}
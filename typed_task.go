@@ -0,0 +1,134 @@
+// Copyright (c) 2019 Sick Yoon
+// This file is part of gocelery which is released under MIT license.
+// See file LICENSE for full license details.
+
+package gocelery
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// TaskRegistrar is implemented by CeleryWorker and CeleryClient
+type TaskRegistrar interface {
+	Register(name string, task interface{})
+}
+
+// typedTask is task decoding message arguments itself
+type typedTask interface {
+	runTyped(args []interface{}, kwargs map[string]interface{}) (interface{}, error)
+}
+
+// typedTaskFunc is function registered by RegisterFunc
+type typedTaskFunc[Args any, Result any] struct {
+	fn func(Args) (Result, error)
+}
+
+func (t *typedTaskFunc[Args, Result]) runTyped(args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+	var in Args
+	if err := decodeTaskArgs(args, kwargs, &in); err != nil {
+		return nil, err
+	}
+	return t.fn(in)
+}
+
+// RegisterFunc registers function taking typed arguments as task.
+// When Args is a struct, positional arguments fill its fields in declaration order
+// and keyword arguments fill fields by their json names.
+// Otherwise Args is decoded from the only positional argument or from kwargs.
+// Arguments are decoded through their json representation, so Args may hold
+// any integer or float types, structs, slices and pointers.
+func RegisterFunc[Args any, Result any](registrar TaskRegistrar, name string, fn func(Args) (Result, error)) {
+	registrar.Register(name, &typedTaskFunc[Args, Result]{fn: fn})
+}
+
+// ArgumentError is returned when task arguments cannot be decoded into task parameters.
+// Worker stores it as FAILURE result with python TypeError.
+type ArgumentError struct {
+	Err error
+}
+
+func (e *ArgumentError) Error() string {
+	return fmt.Sprintf("invalid task arguments: %v", e.Err)
+}
+
+// Unwrap returns decoding error
+func (e *ArgumentError) Unwrap() error {
+	return e.Err
+}
+
+// decodeTaskArgs decodes positional and keyword arguments into value pointed to by out
+func decodeTaskArgs(args []interface{}, kwargs map[string]interface{}, out interface{}) error {
+	outType := reflect.TypeOf(out).Elem()
+	for outType.Kind() == reflect.Ptr {
+		outType = outType.Elem()
+	}
+	if outType.Kind() != reflect.Struct {
+		switch {
+		case len(args) == 1 && len(kwargs) == 0:
+			return decodeArgument(args[0], out, false)
+		case len(args) == 0:
+			return decodeArgument(kwargs, out, false)
+		default:
+			return &ArgumentError{Err: fmt.Errorf("expected 1 argument, got %d positional and %d keyword arguments", len(args), len(kwargs))}
+		}
+	}
+
+	names := argumentNames(outType)
+	if len(args) > len(names) {
+		return &ArgumentError{Err: fmt.Errorf("takes %d positional arguments but %d were given", len(names), len(args))}
+	}
+	values := make(map[string]interface{}, len(args)+len(kwargs))
+	for i, arg := range args {
+		values[names[i]] = arg
+	}
+	for name, val := range kwargs {
+		if _, ok := values[name]; ok {
+			return &ArgumentError{Err: fmt.Errorf("got multiple values for argument '%s'", name)}
+		}
+		values[name] = val
+	}
+	return decodeArgument(values, out, true)
+}
+
+// argumentNames returns json names of exported fields of struct in declaration order
+func argumentNames(structType reflect.Type) []string {
+	names := make([]string, 0, structType.NumField())
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		if field.PkgPath != "" || field.Anonymous {
+			continue
+		}
+		name := field.Name
+		if tag, ok := field.Tag.Lookup("json"); ok {
+			tagName := strings.Split(tag, ",")[0]
+			if tagName == "-" {
+				continue
+			}
+			if tagName != "" {
+				name = tagName
+			}
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+// decodeArgument decodes val into value pointed to by out through its json representation
+func decodeArgument(val interface{}, out interface{}, strict bool) error {
+	valBytes, err := json.Marshal(val)
+	if err != nil {
+		return &ArgumentError{Err: err}
+	}
+	decoder := json.NewDecoder(bytes.NewReader(valBytes))
+	if strict {
+		decoder.DisallowUnknownFields()
+	}
+	if err := decoder.Decode(out); err != nil {
+		return &ArgumentError{Err: err}
+	}
+	return nil
+}
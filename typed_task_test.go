@@ -0,0 +1,78 @@
+// Copyright (c) 2019 Sick Yoon
+// This file is part of gocelery which is released under MIT license.
+// See file LICENSE for full license details.
+
+package gocelery
+
+import (
+	"errors"
+	"testing"
+)
+
+type orderArgs struct {
+	ID       int64   `json:"id"`
+	Quantity uint    `json:"quantity"`
+	Items    []point `json:"items"`
+	Note     *string `json:"note"`
+}
+
+// TestRegisterFunc tests that typed task decodes positional and keyword arguments
+func TestRegisterFunc(t *testing.T) {
+	celeryWorker := NewCeleryWorker(&stubBroker{}, &stubBackend{}, 1)
+	RegisterFunc(celeryWorker, "order", func(args orderArgs) (int64, error) {
+		if args.Note == nil || *args.Note != "gift" {
+			return 0, errors.New("note is not decoded")
+		}
+		return args.ID + int64(args.Quantity) + int64(args.Items[0].X), nil
+	})
+	message := getTaskMessageV2WithKwargs(
+		[]interface{}{float64(1 << 40), 2.0},
+		map[string]interface{}{
+			"items": []interface{}{map[string]interface{}{"x": 3.0, "y": 4.0}},
+			"note":  "gift",
+		},
+	)
+	defer releaseTaskMessageV2(message)
+	res, err := celeryWorker.RunTaskV2("order", "id", message)
+	if err != nil {
+		t.Fatalf("failed to run typed task: %v", err)
+	}
+	if res.Result != int64(1<<40+5) {
+		t.Errorf("unexpected result %v", res.Result)
+	}
+}
+
+// TestRegisterFuncDecodeError tests that invalid arguments are reported instead of panicking
+func TestRegisterFuncDecodeError(t *testing.T) {
+	celeryWorker := NewCeleryWorker(&stubBroker{}, &stubBackend{}, 1)
+	RegisterFunc(celeryWorker, "order", func(args orderArgs) (int64, error) {
+		return args.ID, nil
+	})
+	RegisterFunc(celeryWorker, "square", func(x int) (int, error) {
+		return x * x, nil
+	})
+	for _, tc := range []struct {
+		name   string
+		task   string
+		args   []interface{}
+		kwargs map[string]interface{}
+	}{
+		{name: "type mismatch", task: "order", args: []interface{}{"one"}},
+		{name: "too many arguments", task: "order", args: []interface{}{1.0, 2.0, nil, nil, 5.0}},
+		{name: "duplicate argument", task: "order", args: []interface{}{1.0}, kwargs: map[string]interface{}{"id": 2.0}},
+		{name: "unexpected keyword", task: "order", args: []interface{}{1.0}, kwargs: map[string]interface{}{"price": 2.0}},
+		{name: "fraction into int", task: "square", args: []interface{}{1.5}},
+	} {
+		message := getTaskMessageV2WithKwargs(tc.args, tc.kwargs)
+		res, err := celeryWorker.RunTaskV2(tc.task, "id", message)
+		releaseTaskMessageV2(message)
+		if !isTypeErrorResult(res, err) {
+			t.Errorf("test '%s': expected TypeError failure, got %v %v", tc.name, res, err)
+		}
+	}
+	message := getTaskMessageV2(3.0)
+	defer releaseTaskMessageV2(message)
+	if res, err := celeryWorker.RunTaskV2("square", "id", message); err != nil || res.Result != 9 {
+		t.Errorf("failed to run task with single argument: %v %v", res, err)
+	}
+}
@@ -6,9 +6,14 @@ package gocelery
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"os"
 	"reflect"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 )
@@ -23,6 +28,13 @@ type CeleryWorker struct {
 	cancel          context.CancelFunc
 	workWG          sync.WaitGroup
 	rateLimitPeriod time.Duration
+	rateLimiter     RateLimiter
+	rateLimits      map[string]*RateLimit
+	etaReady        chan func()
+	restorePeriod   time.Duration
+	movePeriod      time.Duration
+	hostname        string
+	useNumber       bool
 }
 
 // NewCeleryWorker returns new celery worker
@@ -33,41 +45,73 @@ func NewCeleryWorker(broker CeleryBroker, backend CeleryBackend, numWorkers int)
 		numWorkers:      numWorkers,
 		registeredTasks: map[string]interface{}{},
 		rateLimitPeriod: 100 * time.Millisecond,
+		rateLimits:      map[string]*RateLimit{},
+		etaReady:        make(chan func()),
+		restorePeriod:   time.Minute,
+		movePeriod:      time.Second,
+		hostname:        defaultHostname(),
 	}
 }
 
+// defaultHostname returns celery style node name of worker
+func defaultHostname() string {
+	hostname, _ := os.Hostname()
+	return fmt.Sprintf("celery@%s", hostname)
+}
+
 // StartWorkerWithContext starts celery worker(s) with given parent context
 func (w *CeleryWorker) StartWorkerWithContext(ctx context.Context) {
 	var wctx context.Context
 	wctx, w.cancel = context.WithCancel(ctx)
+	if restorer, ok := w.broker.(unackedRestorer); ok {
+		w.workWG.Add(1)
+		go func() {
+			defer w.workWG.Done()
+			w.restoreUnacked(wctx, restorer)
+		}()
+	}
+	if mover, ok := w.broker.(delayedMover); ok {
+		w.workWG.Add(1)
+		go func() {
+			defer w.workWG.Done()
+			w.moveDelayed(wctx, mover)
+		}()
+	}
+	if controlBroker, ok := w.broker.(CeleryControlBroker); ok {
+		w.workWG.Add(1)
+		go func() {
+			defer w.workWG.Done()
+			w.consumeControl(wctx, controlBroker)
+		}()
+	}
 	w.workWG.Add(w.numWorkers)
 	for i := 0; i < w.numWorkers; i++ {
 		go func(workerID int) {
 			defer w.workWG.Done()
 			ticker := time.NewTicker(w.rateLimitPeriod)
+			defer ticker.Stop()
 			for {
 				select {
 				case <-wctx.Done():
 					return
+				case run := <-w.etaReady:
+					run()
 				case <-ticker.C:
+					if envelopeBroker, ok := w.broker.(CeleryEnvelopeBroker); ok {
+						envelope, err := envelopeBroker.GetCeleryEnvelope()
+						if err != nil || envelope == nil {
+							continue
+						}
+						w.handleEnvelope(wctx, envelope)
+						continue
+					}
+
 					// try to process v2 message first
 					celeryMessageV2, err := w.broker.GetCeleryMessageV2()
 					if err == nil && celeryMessageV2 != nil {
-						taskMessageV2 := celeryMessageV2.GetTaskMessageV2()
+						taskMessageV2 := celeryMessageV2.getTaskMessageV2(w.useNumber)
 						if taskMessageV2 != nil {
-							defer releaseTaskMessageV2(taskMessageV2)
-							// run v2 task
-							resultMsg, err := w.RunTaskV2(celeryMessageV2.Headers.Task, celeryMessageV2.Headers.ID, taskMessageV2)
-							if err != nil {
-								log.Printf("failed to run v2 task %s: %+v", celeryMessageV2.Headers.Task, err)
-								continue
-							}
-							defer releaseResultMessage(resultMsg)
-							// push result to backend
-							err = w.backend.SetResult(celeryMessageV2.Headers.ID, resultMsg)
-							if err != nil {
-								log.Printf("failed to push result: %+v", err)
-							}
+							w.handleMessageV2(wctx, celeryMessageV2, taskMessageV2, nil)
 							continue
 						}
 					}
@@ -77,27 +121,257 @@ func (w *CeleryWorker) StartWorkerWithContext(ctx context.Context) {
 					if err != nil || taskMessage == nil {
 						continue
 					}
-
-					// run task
-					resultMsg, err := w.RunTask(taskMessage)
-					if err != nil {
-						log.Printf("failed to run task message %s: %+v", taskMessage.ID, err)
-						continue
-					}
-					defer releaseResultMessage(resultMsg)
-
-					// push result to backend
-					err = w.backend.SetResult(taskMessage.ID, resultMsg)
-					if err != nil {
-						log.Printf("failed to push result: %+v", err)
-						continue
-					}
+					w.handleTaskMessage(wctx, taskMessage, nil)
 				}
 			}
 		}(i)
 	}
 }
 
+// handleEnvelope dispatches message to v2 or v1 runner depending on its protocol
+func (w *CeleryWorker) handleEnvelope(ctx context.Context, envelope *CeleryEnvelope) {
+	if envelope.IsV2() {
+		celeryMessage, err := envelope.GetCeleryMessageV2()
+		if err != nil {
+			log.Printf("failed to decode v2 message: %+v", err)
+			nackDelivery(envelope.Delivery, false)
+			return
+		}
+		taskMessage := celeryMessage.getTaskMessageV2(w.useNumber)
+		if taskMessage == nil {
+			nackDelivery(envelope.Delivery, false)
+			return
+		}
+		w.handleMessageV2(ctx, celeryMessage, taskMessage, envelope.Delivery)
+		return
+	}
+	taskMessage, err := envelope.GetTaskMessage()
+	if err != nil {
+		log.Printf("failed to decode v1 message: %+v", err)
+		nackDelivery(envelope.Delivery, false)
+		return
+	}
+	w.handleTaskMessage(ctx, taskMessage, envelope.Delivery)
+}
+
+// restoreUnacked periodically restores messages whose visibility timeout has passed
+func (w *CeleryWorker) restoreUnacked(ctx context.Context, restorer unackedRestorer) {
+	ticker := time.NewTicker(w.restorePeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			count, err := restorer.RestoreUnacked()
+			if err != nil {
+				log.Printf("failed to restore unacked messages: %+v", err)
+				continue
+			}
+			if count > 0 {
+				log.Printf("restored %d unacked messages", count)
+			}
+		}
+	}
+}
+
+// moveDelayed periodically moves messages whose ETA has come to their queues
+func (w *CeleryWorker) moveDelayed(ctx context.Context, mover delayedMover) {
+	ticker := time.NewTicker(w.movePeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := mover.MoveDelayed(); err != nil {
+				log.Printf("failed to move delayed messages: %+v", err)
+			}
+		}
+	}
+}
+
+// ackDelivery acknowledges message fetched with late acknowledgement
+func ackDelivery(delivery Delivery) {
+	if delivery == nil {
+		return
+	}
+	if err := delivery.Ack(); err != nil {
+		log.Printf("failed to acknowledge message: %+v", err)
+	}
+}
+
+// settleDelivery acknowledges message of finished task unless the task rejected it
+func settleDelivery(delivery Delivery, err error) {
+	var rejectErr *RejectError
+	if errors.As(err, &rejectErr) {
+		nackDelivery(delivery, rejectErr.Requeue)
+		return
+	}
+	ackDelivery(delivery)
+}
+
+// nackDelivery gives message fetched with late acknowledgement back to broker
+func nackDelivery(delivery Delivery, requeue bool) {
+	if delivery == nil {
+		return
+	}
+	if err := delivery.Nack(requeue); err != nil {
+		log.Printf("failed to reject message: %+v", err)
+	}
+}
+
+// handleMessageV2 runs v2 task and pushes its result to backend
+// unless the task is scheduled for later or over its rate limit
+func (w *CeleryWorker) handleMessageV2(ctx context.Context, celeryMessage *CeleryMessageV2, taskMessage *TaskMessageV2, delivery Delivery) {
+	// expired task is revoked instead of run, also when it expires while held for its eta
+	if expires, ok := parseETA(celeryMessage.Headers.Expires); ok && expires.Before(time.Now()) {
+		log.Printf("v2 task %s is expired on %s", celeryMessage.Headers.ID, expires)
+		resultMsg := getRevokedResultMessage("expired")
+		defer releaseResultMessage(resultMsg)
+		w.pushResultV2(celeryMessage, resultMsg)
+		ackDelivery(delivery)
+		return
+	}
+	if eta, ok := parseETA(celeryMessage.Headers.Eta); ok && eta.After(time.Now()) {
+		w.holdUntil(ctx, eta, delivery, func() {
+			w.handleMessageV2(ctx, celeryMessage, taskMessage, delivery)
+		})
+		return
+	}
+	defer releaseTaskMessageV2(taskMessage)
+
+	if wait, limited := w.checkRateLimit(celeryMessage.Headers.Task); limited {
+		celeryMessage.Headers.Eta = formatETA(time.Now().Add(wait))
+		if err := w.broker.SendCeleryMessageV2(celeryMessage); err != nil {
+			log.Printf("failed to requeue rate limited v2 task %s: %+v", celeryMessage.Headers.ID, err)
+			nackDelivery(delivery, true)
+			return
+		}
+		ackDelivery(delivery)
+		return
+	}
+
+	// run v2 task
+	resultMsg, err := w.runTaskV2(ctx, celeryMessage.Headers.Task, celeryMessage.Headers.ID, taskMessage)
+	if err != nil {
+		log.Printf("failed to run v2 task %s: %+v", celeryMessage.Headers.Task, err)
+		settleDelivery(delivery, err)
+		return
+	}
+	defer releaseResultMessage(resultMsg)
+	w.pushResultV2(celeryMessage, resultMsg)
+	// acknowledge once the task is done and its result is stored
+	ackDelivery(delivery)
+}
+
+// pushResultV2 pushes result of v2 task to backend, or to reply queue of client with rpc backend
+func (w *CeleryWorker) pushResultV2(celeryMessage *CeleryMessageV2, resultMsg *ResultMessage) {
+	var err error
+	if replyBackend, ok := w.backend.(CeleryReplyBackend); ok && celeryMessage.Properties.ReplyTo != "" {
+		err = replyBackend.SetReplyResult(celeryMessage.Properties.ReplyTo, celeryMessage.Headers.ID, resultMsg)
+	} else {
+		err = w.backend.SetResult(celeryMessage.Headers.ID, resultMsg)
+	}
+	if err != nil {
+		log.Printf("failed to push result: %+v", err)
+	}
+}
+
+// handleTaskMessage runs v1 task and pushes its result to backend
+// unless the task is scheduled for later or over its rate limit
+func (w *CeleryWorker) handleTaskMessage(ctx context.Context, taskMessage *TaskMessage, delivery Delivery) {
+	if taskMessage.ETA != nil {
+		if eta, ok := parseETA(*taskMessage.ETA); ok && eta.After(time.Now()) {
+			w.holdUntil(ctx, eta, delivery, func() {
+				w.handleTaskMessage(ctx, taskMessage, delivery)
+			})
+			return
+		}
+	}
+
+	if wait, limited := w.checkRateLimit(taskMessage.Task); limited {
+		if err := w.requeueTaskMessage(taskMessage, time.Now().Add(wait)); err != nil {
+			log.Printf("failed to requeue rate limited task %s: %+v", taskMessage.ID, err)
+			nackDelivery(delivery, true)
+			return
+		}
+		ackDelivery(delivery)
+		return
+	}
+
+	// run task
+	resultMsg, err := w.runTask(ctx, taskMessage)
+	if err != nil {
+		log.Printf("failed to run task message %s: %+v", taskMessage.ID, err)
+		settleDelivery(delivery, err)
+		return
+	}
+	defer releaseResultMessage(resultMsg)
+
+	// push result to backend
+	if err := w.backend.SetResult(taskMessage.ID, resultMsg); err != nil {
+		log.Printf("failed to push result: %+v", err)
+	}
+	// acknowledge once the task is done and its result is stored
+	ackDelivery(delivery)
+}
+
+// requeueTaskMessage sends v1 task back to broker to be executed at eta
+func (w *CeleryWorker) requeueTaskMessage(taskMessage *TaskMessage, eta time.Time) error {
+	etaStr := formatETA(eta)
+	taskMessage.ETA = &etaStr
+	encodedMessage, err := taskMessage.Encode()
+	if err != nil {
+		return err
+	}
+	celeryMessage := getCeleryMessage(encodedMessage)
+	defer releaseCeleryMessage(celeryMessage)
+	return w.broker.SendCeleryMessage(celeryMessage)
+}
+
+// holdUntil keeps message in memory and hands run back to worker goroutines at eta.
+// Message is given back to broker if worker shuts down in the meantime.
+func (w *CeleryWorker) holdUntil(ctx context.Context, eta time.Time, delivery Delivery, run func()) {
+	go func() {
+		timer := time.NewTimer(time.Until(eta))
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+			select {
+			case w.etaReady <- run:
+			case <-ctx.Done():
+				nackDelivery(delivery, true)
+			}
+		case <-ctx.Done():
+			nackDelivery(delivery, true)
+		}
+	}()
+}
+
+// checkRateLimit reports whether task is over its shared quota
+// and how long it should wait before running
+func (w *CeleryWorker) checkRateLimit(taskName string) (time.Duration, bool) {
+	w.taskLock.RLock()
+	limit, ok := w.rateLimits[taskName]
+	limiter := w.rateLimiter
+	w.taskLock.RUnlock()
+	if !ok || limiter == nil {
+		return 0, false
+	}
+	key := limit.Key
+	if key == "" {
+		key = taskName
+	}
+	allowed, wait, err := limiter.Allow(key, limit.Limit, limit.Period)
+	if err != nil {
+		// fail open so an unavailable limiter does not stall every task
+		log.Printf("failed to check rate limit %s: %+v", key, err)
+		return 0, false
+	}
+	return wait, !allowed
+}
+
 // StartWorker starts celery workers
 func (w *CeleryWorker) StartWorker() {
 	w.StartWorkerWithContext(context.Background())
@@ -107,11 +381,34 @@ func (w *CeleryWorker) StartWorker() {
 func (w *CeleryWorker) StopWorker() {
 	w.cancel()
 	w.workWG.Wait()
+	w.stopConsuming()
 }
 
 // StopWait waits for celery workers to terminate
 func (w *CeleryWorker) StopWait() {
 	w.workWG.Wait()
+	w.stopConsuming()
+}
+
+// stopConsuming gives messages prefetched by broker back once workers are gone
+func (w *CeleryWorker) stopConsuming() {
+	if stopper, ok := w.broker.(consumerStopper); ok {
+		if err := stopper.StopConsuming(); err != nil {
+			log.Printf("failed to stop consuming: %+v", err)
+		}
+	}
+}
+
+// SetHostname sets node name used to address remote control commands to worker
+func (w *CeleryWorker) SetHostname(hostname string) {
+	w.hostname = hostname
+}
+
+// SetUseNumber makes worker decode numeric arguments of v2 messages as json.Number.
+// Integer arguments are then converted to task parameters without precision loss.
+// It must be set before worker is started.
+func (w *CeleryWorker) SetUseNumber(useNumber bool) {
+	w.useNumber = useNumber
 }
 
 // GetNumWorkers returns number of currently running workers
@@ -126,6 +423,38 @@ func (w *CeleryWorker) Register(name string, task interface{}) {
 	w.taskLock.Unlock()
 }
 
+// RegisterFactory registers task created by factory for every execution.
+// Dependencies shared by executions can be captured by factory.
+func (w *CeleryWorker) RegisterFactory(name string, factory CeleryTaskFactory) {
+	w.Register(name, factory)
+}
+
+// SetRateLimiter sets limiter used to enforce task rate limits
+func (w *CeleryWorker) SetRateLimiter(limiter RateLimiter) {
+	w.taskLock.Lock()
+	w.rateLimiter = limiter
+	w.taskLock.Unlock()
+}
+
+// SetRateLimit declares shared quota for task, nil limit removes it.
+// Tasks over quota are sent back to broker with a countdown.
+// It returns error when Limit is not positive or Period is shorter than 1ms.
+func (w *CeleryWorker) SetRateLimit(name string, limit *RateLimit) error {
+	if limit != nil {
+		if err := limit.validate(); err != nil {
+			return err
+		}
+	}
+	w.taskLock.Lock()
+	if limit == nil {
+		delete(w.rateLimits, name)
+	} else {
+		w.rateLimits[name] = limit
+	}
+	w.taskLock.Unlock()
+	return nil
+}
+
 // GetTask retrieves registered task
 func (w *CeleryWorker) GetTask(name string) interface{} {
 	w.taskLock.RLock()
@@ -140,6 +469,11 @@ func (w *CeleryWorker) GetTask(name string) interface{} {
 
 // RunTaskV2 runs celery task from v2 message
 func (w *CeleryWorker) RunTaskV2(taskName string, taskID string, message *TaskMessageV2) (*ResultMessage, error) {
+	return w.runTaskV2(context.Background(), taskName, taskID, message)
+}
+
+// runTaskV2 runs celery task from v2 message, ctx is passed to ContextTask
+func (w *CeleryWorker) runTaskV2(ctx context.Context, taskName string, taskID string, message *TaskMessageV2) (*ResultMessage, error) {
 	// check for malformed task message - args cannot be nil
 	if message.Args == nil {
 		return nil, fmt.Errorf("task %s is malformed - args cannot be nil", taskID)
@@ -151,6 +485,12 @@ func (w *CeleryWorker) RunTaskV2(taskName string, taskID string, message *TaskMe
 		return nil, fmt.Errorf("task %s is not registered", taskName)
 	}
 
+	// factory creates new task instance for this execution
+	task, err := newTaskInstance(task)
+	if err != nil {
+		return nil, err
+	}
+
 	// convert to task interface
 	taskInterface, ok := task.(CeleryTask)
 	if ok {
@@ -164,22 +504,70 @@ func (w *CeleryWorker) RunTaskV2(taskName string, taskID string, message *TaskMe
 		return getResultMessage(val), err
 	}
 
+	// typed task decodes its arguments itself
+	if typed, ok := task.(typedTask); ok {
+		// task and argument errors are stored as FAILURE
+		return getTaskResultMessage(typed.runTyped(message.Args, message.Kwargs))
+	}
+
+	// task struct is bound from arguments by its celery tags
+	if contextTask, ok := task.(ContextTask); ok {
+		// task and argument errors are stored as FAILURE
+		return getTaskResultMessage(runContextTask(ctx, contextTask, message.Args, message.Kwargs))
+	}
+
 	// use reflection to execute function ptr
 	taskFunc := reflect.ValueOf(task)
 	return runTaskFuncV2(&taskFunc, message)
 }
 
+// newTaskInstance returns task created by factory or registered task itself
+func newTaskInstance(task interface{}) (interface{}, error) {
+	var factory CeleryTaskFactory
+	switch f := task.(type) {
+	case CeleryTaskFactory:
+		factory = f
+	case func() CeleryTask:
+		factory = f
+	default:
+		return task, nil
+	}
+	instance := factory()
+	if instance == nil {
+		return nil, fmt.Errorf("task factory returned nil task")
+	}
+	return instance, nil
+}
+
 func runTaskFuncV2(taskFunc *reflect.Value, message *TaskMessageV2) (*ResultMessage, error) {
+	// params struct binds positional and keyword arguments by name
+	if structType, ok := paramsStructType(taskFunc.Type()); ok {
+		return runParamsStructFunc(taskFunc, structType, message.Args, message.Kwargs)
+	}
+
+	// parameters of plain function have no names keyword arguments could bind to
+	if err := checkNoKwargs(message.Kwargs); err != nil {
+		return getTaskResultMessage(nil, err)
+	}
+
 	// check number of arguments
 	numArgs := taskFunc.Type().NumIn()
 	messageNumArgs := len(message.Args)
 	if numArgs != messageNumArgs {
-		return nil, fmt.Errorf("Number of task arguments %d does not match number of message arguments %d", numArgs, messageNumArgs)
+		return getTaskResultMessage(nil, &ArgumentError{Err: fmt.Errorf("takes %d positional arguments but %d were given", numArgs, messageNumArgs)})
 	}
 
 	// construct arguments
 	in := make([]reflect.Value, messageNumArgs)
 	for i, arg := range message.Args {
+		// json.Number is converted exactly to parameter type
+		if number, ok := arg.(json.Number); ok {
+			in[i] = reflect.New(taskFunc.Type().In(i)).Elem()
+			if err := coerceValue(number, in[i]); err != nil {
+				return getTaskResultMessage(nil, &ArgumentError{Err: fmt.Errorf("argument %d: %v", i, err)})
+			}
+			continue
+		}
 		origType := taskFunc.Type().In(i).Kind()
 		msgType := reflect.TypeOf(arg).Kind()
 		// special case - convert float64 to int if applicable
@@ -194,17 +582,31 @@ func runTaskFuncV2(taskFunc *reflect.Value, message *TaskMessageV2) (*ResultMess
 		in[i] = reflect.ValueOf(arg)
 	}
 
-	// call method
-	res := taskFunc.Call(in)
-	if len(res) == 0 {
-		return nil, nil
-	}
+	// call method, error returned as last value is stored as FAILURE
+	return getTaskResultMessage(reflectionResult(taskFunc.Call(in)))
+}
 
-	return getReflectionResultMessage(&res[0]), nil
+// checkNoKwargs rejects keyword arguments passed to function registered without parameter names
+func checkNoKwargs(kwargs map[string]interface{}) error {
+	if len(kwargs) == 0 {
+		return nil
+	}
+	names := make([]string, 0, len(kwargs))
+	for name := range kwargs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return &ArgumentError{Err: fmt.Errorf("got unexpected keyword arguments %s, register task with RegisterParams to pass them by name",
+		strings.Join(names, ", "))}
 }
 
 // RunTask runs celery task
 func (w *CeleryWorker) RunTask(message *TaskMessage) (*ResultMessage, error) {
+	return w.runTask(context.Background(), message)
+}
+
+// runTask runs celery task, ctx is passed to ContextTask
+func (w *CeleryWorker) runTask(ctx context.Context, message *TaskMessage) (*ResultMessage, error) {
 
 	// ignore if the message is expired
 	if message.Expires != nil && message.Expires.UTC().Before(time.Now().UTC()) {
@@ -222,6 +624,12 @@ func (w *CeleryWorker) RunTask(message *TaskMessage) (*ResultMessage, error) {
 		return nil, fmt.Errorf("task %s is not registered", message.Task)
 	}
 
+	// factory creates new task instance for this execution
+	task, err := newTaskInstance(task)
+	if err != nil {
+		return nil, err
+	}
+
 	// convert to task interface
 	taskInterface, ok := task.(CeleryTask)
 	if ok {
@@ -235,6 +643,18 @@ func (w *CeleryWorker) RunTask(message *TaskMessage) (*ResultMessage, error) {
 		return getResultMessage(val), err
 	}
 
+	// typed task decodes its arguments itself
+	if typed, ok := task.(typedTask); ok {
+		// task and argument errors are stored as FAILURE
+		return getTaskResultMessage(typed.runTyped(message.Args, message.Kwargs))
+	}
+
+	// task struct is bound from arguments by its celery tags
+	if contextTask, ok := task.(ContextTask); ok {
+		// task and argument errors are stored as FAILURE
+		return getTaskResultMessage(runContextTask(ctx, contextTask, message.Args, message.Kwargs))
+	}
+
 	// use reflection to execute function ptr
 	taskFunc := reflect.ValueOf(task)
 	return runTaskFunc(&taskFunc, message)
@@ -242,11 +662,21 @@ func (w *CeleryWorker) RunTask(message *TaskMessage) (*ResultMessage, error) {
 
 func runTaskFunc(taskFunc *reflect.Value, message *TaskMessage) (*ResultMessage, error) {
 
+	// params struct binds positional and keyword arguments by name
+	if structType, ok := paramsStructType(taskFunc.Type()); ok {
+		return runParamsStructFunc(taskFunc, structType, message.Args, message.Kwargs)
+	}
+
+	// parameters of plain function have no names keyword arguments could bind to
+	if err := checkNoKwargs(message.Kwargs); err != nil {
+		return getTaskResultMessage(nil, err)
+	}
+
 	// check number of arguments
 	numArgs := taskFunc.Type().NumIn()
 	messageNumArgs := len(message.Args)
 	if numArgs != messageNumArgs {
-		return nil, fmt.Errorf("Number of task arguments %d does not match number of message arguments %d", numArgs, messageNumArgs)
+		return getTaskResultMessage(nil, &ArgumentError{Err: fmt.Errorf("takes %d positional arguments but %d were given", numArgs, messageNumArgs)})
 	}
 
 	// construct arguments
@@ -266,11 +696,33 @@ func runTaskFunc(taskFunc *reflect.Value, message *TaskMessage) (*ResultMessage,
 		in[i] = reflect.ValueOf(arg)
 	}
 
-	// call method
-	res := taskFunc.Call(in)
-	if len(res) == 0 {
-		return nil, nil
-	}
+	// call method, error returned as last value is stored as FAILURE
+	return getTaskResultMessage(reflectionResult(taskFunc.Call(in)))
+}
+
+// isoTimeFormat matches python datetime.isoformat() used by celery for eta
+const isoTimeFormat = "2006-01-02T15:04:05.000000-07:00"
+
+func formatETA(t time.Time) string {
+	return t.UTC().Format(isoTimeFormat)
+}
 
-	return getReflectionResultMessage(&res[0]), nil
+// parseETA parses eta header into time
+func parseETA(eta interface{}) (time.Time, bool) {
+	switch v := eta.(type) {
+	case time.Time:
+		return v, true
+	case string:
+		if v == "" {
+			return time.Time{}, false
+		}
+		if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+			return t, true
+		}
+		// naive datetime is utc when CELERY_ENABLE_UTC is set
+		if t, err := time.Parse("2006-01-02T15:04:05.999999", v); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
 }
@@ -6,8 +6,10 @@ package gocelery
 
 import (
 	"context"
+	"errors"
 	"math/rand"
 	"reflect"
+	"sync"
 	"testing"
 	"time"
 
@@ -152,6 +154,39 @@ func TestWorkerExpiredTask(t *testing.T) {
 	}
 }
 
+// TestWorkerExpiredTaskV2 tests that expired v2 task is stored as REVOKED and acknowledged without running
+func TestWorkerExpiredTaskV2(t *testing.T) {
+	backend := &stubBackend{}
+	celeryWorker := NewCeleryWorker(&stubBroker{}, backend, 1)
+	ran := false
+	celeryWorker.Register("add", func(a, b int) int {
+		ran = true
+		return a + b
+	})
+
+	taskMessage := getTaskMessageV2(1, 2)
+	encoded, _ := taskMessage.Encode()
+	headers := buildCeleryHeadersV2("add", taskMessage.Args, nil)
+	headers.Expires = formatETA(time.Now().Add(-time.Second))
+	celeryMessage := getCeleryMessageV2(encoded, *headers)
+	delivery := &recordingDelivery{}
+	celeryWorker.handleMessageV2(context.Background(), celeryMessage, taskMessage, delivery)
+
+	if ran {
+		t.Error("expired task was run")
+	}
+	if acked, nacked, _ := delivery.settled(); !acked || nacked {
+		t.Errorf("expected delivery to be acknowledged, got acked %t nacked %t", acked, nacked)
+	}
+	res, err := backend.GetResult(headers.ID)
+	if err != nil || res.Status != "REVOKED" {
+		t.Fatalf("expected REVOKED result, got %v: %v", res, err)
+	}
+	if exc := res.Result.(map[string]interface{}); exc["exc_type"] != "TaskRevokedError" {
+		t.Errorf("unexpected revoked result %v", res.Result)
+	}
+}
+
 // TestWorkerNumWorkers ensures correct number of workers is set
 func TestWorkerNumWorkers(t *testing.T) {
 	testCases := []struct {
@@ -224,3 +259,291 @@ func TestWorkerStartStop(t *testing.T) {
 		}()
 	}
 }
+
+// recordingDelivery records how a delivery was settled
+type recordingDelivery struct {
+	sync.Mutex
+	acked    bool
+	nacked   bool
+	requeued bool
+}
+
+func (d *recordingDelivery) Ack() error {
+	d.Lock()
+	defer d.Unlock()
+	d.acked = true
+	return nil
+}
+
+func (d *recordingDelivery) Nack(requeue bool) error {
+	d.Lock()
+	defer d.Unlock()
+	d.nacked, d.requeued = true, requeue
+	return nil
+}
+
+func (d *recordingDelivery) settled() (acked, nacked, requeued bool) {
+	d.Lock()
+	defer d.Unlock()
+	return d.acked, d.nacked, d.requeued
+}
+
+// TestWorkerAcksLate tests that delivery is acknowledged after result is stored
+func TestWorkerAcksLate(t *testing.T) {
+	backend := &stubBackend{}
+	celeryWorker := NewCeleryWorker(&stubBroker{}, backend, 1)
+	celeryWorker.Register("add", add)
+
+	taskMessage := getTaskMessageV2(1, 2)
+	encoded, _ := taskMessage.Encode()
+	headers := buildCeleryHeadersV2("add", taskMessage.Args, nil)
+	celeryMessage := getCeleryMessageV2(encoded, *headers)
+	delivery := &recordingDelivery{}
+	celeryWorker.handleMessageV2(context.Background(), celeryMessage, taskMessage, delivery)
+
+	if acked, nacked, _ := delivery.settled(); !acked || nacked {
+		t.Errorf("expected delivery to be acknowledged, got acked %t nacked %t", acked, nacked)
+	}
+	if _, err := backend.GetResult(headers.ID); err != nil {
+		t.Errorf("expected result to be stored before acknowledgement: %v", err)
+	}
+}
+
+// rejectTask rejects its message
+type rejectTask struct{}
+
+func (r *rejectTask) ParseKwargs(kwargs map[string]interface{}) error {
+	return nil
+}
+
+func (r *rejectTask) RunTask() (interface{}, error) {
+	return nil, NewRejectError(errors.New("downstream unavailable"), true)
+}
+
+// TestWorkerRejectRequeue tests that a rejected task requeues its delivery
+func TestWorkerRejectRequeue(t *testing.T) {
+	celeryWorker := NewCeleryWorker(&stubBroker{}, &stubBackend{}, 1)
+	celeryWorker.Register("reject", &rejectTask{})
+
+	taskMessage := getTaskMessageV2()
+	encoded, _ := taskMessage.Encode()
+	headers := buildCeleryHeadersV2("reject", nil, nil)
+	celeryMessage := getCeleryMessageV2(encoded, *headers)
+	delivery := &recordingDelivery{}
+	celeryWorker.handleMessageV2(context.Background(), celeryMessage, taskMessage, delivery)
+
+	if acked, nacked, requeued := delivery.settled(); acked || !nacked || !requeued {
+		t.Errorf("expected delivery to be requeued, got acked %t nacked %t", acked, nacked)
+	}
+}
+
+// TestWorkerNackHeldOnShutdown tests that messages held for eta are given back on shutdown
+func TestWorkerNackHeldOnShutdown(t *testing.T) {
+	celeryWorker := NewCeleryWorker(&stubBroker{}, &stubBackend{}, 1)
+	celeryWorker.Register("add", add)
+
+	taskMessage := getTaskMessageV2(1, 2)
+	encoded, _ := taskMessage.Encode()
+	headers := buildCeleryHeadersV2("add", taskMessage.Args, nil)
+	headers.Eta = formatETA(time.Now().Add(time.Hour))
+	celeryMessage := getCeleryMessageV2(encoded, *headers)
+	delivery := &recordingDelivery{}
+	ctx, cancel := context.WithCancel(context.Background())
+	celeryWorker.handleMessageV2(ctx, celeryMessage, taskMessage, delivery)
+	cancel()
+	time.Sleep(50 * time.Millisecond)
+
+	if acked, _, requeued := delivery.settled(); acked || !requeued {
+		t.Errorf("expected held delivery to be requeued, got acked %t requeued %t", acked, requeued)
+	}
+}
+
+// counterTask keeps parsed kwargs and its dependency in fields
+type counterTask struct {
+	step    *int64
+	a, b    int
+	running bool
+}
+
+func (c *counterTask) ParseKwargs(kwargs map[string]interface{}) error {
+	c.a = int(kwargs["a"].(float64))
+	c.b = int(kwargs["b"].(float64))
+	return nil
+}
+
+func (c *counterTask) RunTask() (interface{}, error) {
+	if c.running {
+		return nil, errors.New("task instance is shared")
+	}
+	c.running = true
+	time.Sleep(time.Millisecond)
+	return c.a + c.b + int(*c.step), nil
+}
+
+// TestWorkerRegisterFactory tests that factory creates task instance for every execution
+func TestWorkerRegisterFactory(t *testing.T) {
+	step := int64(10)
+	celeryWorker := NewCeleryWorker(&stubBroker{}, &stubBackend{}, 4)
+	celeryWorker.RegisterFactory("counter", func() CeleryTask {
+		return &counterTask{step: &step}
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			message := getTaskMessageV2WithKwargs([]interface{}{}, map[string]interface{}{"a": float64(i), "b": 1.0})
+			defer releaseTaskMessageV2(message)
+			message.Args = []interface{}{}
+			res, err := celeryWorker.RunTaskV2("counter", uuid.New().String(), message)
+			if err != nil {
+				t.Errorf("failed to run task: %v", err)
+				return
+			}
+			if res.Result != i+11 {
+				t.Errorf("expected result %d, got %v", i+11, res.Result)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	celeryWorker.RegisterFactory("nil", func() CeleryTask { return nil })
+	message := getTaskMessageV2WithKwargs([]interface{}{}, nil)
+	defer releaseTaskMessageV2(message)
+	message.Args = []interface{}{}
+	if _, err := celeryWorker.RunTaskV2("nil", uuid.New().String(), message); err == nil {
+		t.Error("expected error for factory returning nil task")
+	}
+}
+
+// report is struct returned by reflected task
+type report struct {
+	Name    string    `json:"name"`
+	Total   int       `json:"total"`
+	Created time.Time `json:"created"`
+}
+
+// TestWorkerErrorReturn tests that reflected tasks return structs and store errors as FAILURE
+func TestWorkerErrorReturn(t *testing.T) {
+	created := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	celeryWorker := NewCeleryWorker(&stubBroker{}, &stubBackend{}, 1)
+	celeryWorker.Register("report", func(name string) (report, error) {
+		if name == "" {
+			return report{}, errors.New("name is empty")
+		}
+		return report{Name: name, Total: 3, Created: created}, nil
+	})
+	celeryWorker.Register("reportPtr", func(name string) (*report, error) {
+		return &report{Name: name}, nil
+	})
+	celeryWorker.Register("created", func() time.Time { return created })
+	celeryWorker.Register("check", func(name string) error {
+		return NewRejectError(errors.New(name), false)
+	})
+
+	testCases := []struct {
+		name     string
+		taskName string
+		args     []interface{}
+		status   string
+		expected interface{}
+	}{
+		{name: "struct", taskName: "report", args: []interface{}{"daily"}, status: "SUCCESS", expected: report{Name: "daily", Total: 3, Created: created}},
+		{name: "pointer", taskName: "reportPtr", args: []interface{}{"daily"}, status: "SUCCESS", expected: &report{Name: "daily"}},
+		{name: "time", taskName: "created", args: []interface{}{}, status: "SUCCESS", expected: created},
+		{name: "error", taskName: "report", args: []interface{}{""}, status: "FAILURE", expected: map[string]interface{}{
+			"exc_type":    "Exception",
+			"exc_module":  "builtins",
+			"exc_message": []interface{}{"name is empty"},
+		}},
+	}
+	for _, tc := range testCases {
+		message := getTaskMessageV2(tc.args...)
+		res, err := celeryWorker.RunTaskV2(tc.taskName, uuid.New().String(), message)
+		releaseTaskMessageV2(message)
+		if err != nil {
+			t.Errorf("test '%s': failed to run task: %v", tc.name, err)
+			continue
+		}
+		if res.Status != tc.status || !reflect.DeepEqual(res.Result, tc.expected) {
+			t.Errorf("test '%s': expected %s %v, got %s %v", tc.name, tc.status, tc.expected, res.Status, res.Result)
+		}
+		releaseResultMessage(res)
+	}
+
+	message := getTaskMessageV2("invalid")
+	defer releaseTaskMessageV2(message)
+	_, err := celeryWorker.RunTaskV2("check", uuid.New().String(), message)
+	var rejectErr *RejectError
+	if !errors.As(err, &rejectErr) {
+		t.Errorf("expected reject error, got %v", err)
+	}
+}
+
+// TestWorkerTypedTaskFailure tests that error of typed task is stored as FAILURE result
+func TestWorkerTypedTaskFailure(t *testing.T) {
+	backend := &stubBackend{}
+	celeryWorker := NewCeleryWorker(&stubBroker{}, backend, 1)
+	RegisterFunc(celeryWorker, "charge", func(amount int) (int, error) {
+		return 0, errors.New("card declined")
+	})
+
+	taskMessage := getTaskMessageV2(10)
+	encoded, _ := taskMessage.Encode()
+	headers := buildCeleryHeadersV2("charge", taskMessage.Args, nil)
+	celeryMessage := getCeleryMessageV2(encoded, *headers)
+	delivery := &recordingDelivery{}
+	celeryWorker.handleMessageV2(context.Background(), celeryMessage, taskMessage, delivery)
+
+	if acked, _, _ := delivery.settled(); !acked {
+		t.Error("expected delivery of failed task to be acknowledged")
+	}
+	result, err := backend.GetResult(headers.ID)
+	if err != nil {
+		t.Fatalf("expected failure to be stored: %v", err)
+	}
+	var failedErr *TaskFailedError
+	if !errors.As(newTaskFailedError(headers.ID, result), &failedErr) {
+		t.Fatalf("expected FAILURE result, got %s %v", result.Status, result.Result)
+	}
+	if failedErr.Error() != "task "+headers.ID+" FAILURE: Exception: [card declined]" {
+		t.Errorf("unexpected failure %v", failedErr)
+	}
+
+	// invalid arguments are stored as TypeError like celery does
+	message := getTaskMessageV2("ten")
+	defer releaseTaskMessageV2(message)
+	if res, err := celeryWorker.RunTaskV2("charge", "id", message); !isTypeErrorResult(res, err) {
+		t.Errorf("expected TypeError failure, got %v %v", res, err)
+	}
+}
+
+// isTypeErrorResult reports whether task run stored FAILURE with TypeError
+func isTypeErrorResult(res *ResultMessage, err error) bool {
+	if err != nil || res == nil || res.Status != "FAILURE" {
+		return false
+	}
+	exc, ok := res.Result.(map[string]interface{})
+	return ok && exc["exc_type"] == "TypeError"
+}
+
+// TestWorkerPlainFuncKwargs tests that keyword arguments are rejected by function without parameter names
+func TestWorkerPlainFuncKwargs(t *testing.T) {
+	celeryWorker := NewCeleryWorker(&stubBroker{}, &stubBackend{}, 1)
+	celeryWorker.Register("add", func(a, b int) int { return a + b })
+
+	messageV2 := getTaskMessageV2WithKwargs([]interface{}{1.0, 2.0}, map[string]interface{}{"b": 5.0})
+	defer releaseTaskMessageV2(messageV2)
+	if res, err := celeryWorker.RunTaskV2("add", uuid.New().String(), messageV2); !isTypeErrorResult(res, err) {
+		t.Errorf("expected TypeError failure for v2 keyword arguments, got %v %v", res, err)
+	}
+
+	message := getTaskMessage("add")
+	defer releaseTaskMessage(message)
+	message.Args = []interface{}{1.0, 2.0}
+	message.Kwargs["b"] = 5.0
+	if res, err := celeryWorker.RunTask(message); !isTypeErrorResult(res, err) {
+		t.Errorf("expected TypeError failure for v1 keyword arguments, got %v %v", res, err)
+	}
+}